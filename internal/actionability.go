@@ -0,0 +1,111 @@
+package webscenario
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultTimeout is the timeout for waiting elements and actions, if it is not specified by a flag or an option.
+const DefaultTimeout = 5 * time.Second
+
+// actionabilityInterval is the interval to check actionability of an element again.
+const actionabilityInterval = 100 * time.Millisecond
+
+// Actionability is a set of conditions that an element should satisfy before an action.
+// An element is always checked that it is attached to the document.
+type Actionability int
+
+const (
+	// Visible means the element has non-empty bounding box and is not hidden by visibility style.
+	Visible Actionability = 1 << iota
+
+	// Enabled means the element is not a disabled form control.
+	Enabled
+
+	// Stable means the element is not moving, that is, the bounding box is the same in two animation frames.
+	Stable
+)
+
+// actionabilityFunction returns the reason why the element is not actionable, or empty string if it is actionable.
+const actionabilityFunction = `async function(visible, enabled, stable) {
+	if (!this.isConnected) {
+		return "not attached";
+	}
+	const rect = this.getBoundingClientRect();
+	if (visible && (rect.width === 0 || rect.height === 0 || getComputedStyle(this).visibility !== "visible")) {
+		return "not visible";
+	}
+	if (enabled && (this.disabled || this.closest("fieldset:disabled, [aria-disabled=true]"))) {
+		return "not enabled";
+	}
+	if (stable) {
+		// setTimeout is a fallback for the case that animation frames are throttled.
+		await new Promise((resolve) => { requestAnimationFrame(() => requestAnimationFrame(resolve)); setTimeout(resolve, 100); });
+		const r = this.getBoundingClientRect();
+		if (r.x !== rect.x || r.y !== rect.y || r.width !== rect.width || r.height !== rect.height) {
+			return "not stable";
+		}
+	}
+	return "";
+}`
+
+func checkActionability(ctx context.Context, nodeID cdp.NodeID, checks Actionability) (string, error) {
+	obj, err := dom.ResolveNode().WithNodeID(nodeID).Do(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", err
+		}
+		return "not attached", nil
+	}
+	defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+
+	var reason string
+	err = chromedp.CallFunctionOn(
+		actionabilityFunction,
+		&reason,
+		func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+			return p.WithObjectID(obj.ObjectID).WithAwaitPromise(true)
+		},
+		checks&Visible != 0,
+		checks&Enabled != 0,
+		checks&Stable != 0,
+	).Do(ctx)
+	return reason, err
+}
+
+// WaitActionable makes an action to wait until the node satisfies the checks.
+// It fails immediately if the node is detached from the document, because the node will never be attached again.
+// Otherwise it keeps waiting until the context is done, and reports the last reason.
+func WaitActionable(nodeID cdp.NodeID, checks Actionability) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		reason := ""
+		for {
+			r, err := checkActionability(ctx, nodeID, checks)
+			if err != nil {
+				if ctx.Err() != nil && reason != "" {
+					return errors.New("element is " + reason)
+				}
+				return err
+			}
+			switch r {
+			case "":
+				return nil
+			case "not attached":
+				return errors.New("element is not attached")
+			}
+			reason = r
+
+			select {
+			case <-ctx.Done():
+				return errors.New("element is " + reason)
+			case <-time.After(actionabilityInterval):
+			}
+		}
+	})
+}
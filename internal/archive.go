@@ -0,0 +1,89 @@
+package webscenario
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// paperSizes is a map of paper name and size in inches.
+var paperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"ledger":  {17, 11},
+	"a0":      {33.1, 46.8},
+	"a1":      {23.4, 33.1},
+	"a2":      {16.54, 23.4},
+	"a3":      {11.7, 16.54},
+	"a4":      {8.27, 11.7},
+	"a5":      {5.83, 8.27},
+	"a6":      {4.13, 5.83},
+}
+
+func (t *Tab) PDF(L *lua.LState) int {
+	name, opts, pos := checkNameAndOption(L)
+
+	params := page.PrintToPDF()
+	if opts != nil {
+		params = params.
+			WithLandscape(lua.LVAsBool(L.GetField(opts, "landscape"))).
+			WithPrintBackground(lua.LVAsBool(L.GetField(opts, "printBackground")))
+
+		switch p := L.GetField(opts, "paper").(type) {
+		case *lua.LNilType:
+		case lua.LString:
+			size, ok := paperSizes[strings.ToLower(string(p))]
+			if !ok {
+				L.ArgError(pos, `paper field expected "letter", "legal", "tabloid", "ledger", or "a0" to "a6".`)
+			}
+			params = params.WithPaperWidth(size[0]).WithPaperHeight(size[1])
+		default:
+			L.ArgError(pos, "paper field expected string value.")
+		}
+	}
+
+	var path string
+	t.Run(
+		L,
+		fmt.Sprintf("$:pdf(%v)", name),
+		false,
+		0,
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			buf, _, err := params.Do(ctx)
+			if err != nil {
+				return err
+			}
+			path, err = t.Save(name, ".pdf", buf)
+			return err
+		}),
+	)
+	L.Push(lua.LString(path))
+	return 1
+}
+
+func (t *Tab) MHTML(L *lua.LState) int {
+	name := L.OptString(2, "")
+
+	var path string
+	t.Run(
+		L,
+		fmt.Sprintf("$:mhtml(%v)", name),
+		false,
+		0,
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			data, err := page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+			if err != nil {
+				return err
+			}
+			path, err = t.Save(name, ".mhtml", []byte(data))
+			return err
+		}),
+	)
+	L.Push(lua.LString(path))
+	return 1
+}
@@ -9,14 +9,28 @@ import (
 )
 
 type Arg struct {
-	Mode      string
-	Args      []string
-	Target    *ayd.URL
-	Alert     ayd.Record
-	Timeout   time.Duration
-	Debug     bool
-	Head      bool
-	Recording bool
+	Mode        string
+	Args        []string
+	Target      *ayd.URL
+	Alert       ayd.Record
+	Timeout     time.Duration
+	Debug       bool
+	Head        bool
+	Recording   bool
+	Screencast  string
+	UserDataDir string
+	BrowserURL  string
+	Browser     BrowserOptions
+
+	// DefaultTimeout is the default timeout for waiting elements and actions.
+	// Zero or negative value means no limit, as same as the timeout option of tabs.
+	DefaultTimeout time.Duration
+
+	// FailOnHTTPError makes navigations in tabs fail by HTTP error status, unless a tab overrides it.
+	FailOnHTTPError bool
+
+	// Evidence enables to save screenshot, HTML, and logs of each tab when the scenario failed.
+	Evidence bool
 }
 
 func (a Arg) ArtifactDir(basedir string) string {
@@ -83,7 +97,7 @@ func (a Arg) Register(L *lua.LState) {
 	L.SetField(tbl, "target", URLToTable(L, a.Target))
 	L.SetField(tbl, "debug", lua.LBool(a.Debug))
 	L.SetField(tbl, "head", lua.LBool(a.Head))
-	L.SetField(tbl, "recording", lua.LBool(a.Recording))
+	L.SetField(tbl, "recording", lua.LBool(a.Recording || a.Screencast != ""))
 
 	if a.Alert.Target != nil {
 		ar := L.NewTable()
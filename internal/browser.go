@@ -0,0 +1,297 @@
+package webscenario
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// BrowserOptions is a set of options to launch the browser.
+type BrowserOptions struct {
+	ExecPath         string
+	Proxy            string
+	Lang             string
+	IgnoreCertErrors bool
+	WindowWidth      int
+	WindowHeight     int
+	Flags            []string
+}
+
+// Merge overwrites options by x.
+// The string and number options in x are used if they are not zero, and the flags are appended.
+func (o BrowserOptions) Merge(x BrowserOptions) BrowserOptions {
+	if x.ExecPath != "" {
+		o.ExecPath = x.ExecPath
+	}
+	if x.Proxy != "" {
+		o.Proxy = x.Proxy
+	}
+	if x.Lang != "" {
+		o.Lang = x.Lang
+	}
+	o.IgnoreCertErrors = o.IgnoreCertErrors || x.IgnoreCertErrors
+	if x.WindowWidth > 0 && x.WindowHeight > 0 {
+		o.WindowWidth = x.WindowWidth
+		o.WindowHeight = x.WindowHeight
+	}
+	o.Flags = append(append([]string{}, o.Flags...), x.Flags...)
+	return o
+}
+
+// IsZero reports whether no option is set.
+func (o BrowserOptions) IsZero() bool {
+	return o.ExecPath == "" && o.Proxy == "" && o.Lang == "" && !o.IgnoreCertErrors && o.WindowWidth == 0 && o.WindowHeight == 0 && len(o.Flags) == 0
+}
+
+// ExecAllocatorOptions converts options to chromedp's options.
+func (o BrowserOptions) ExecAllocatorOptions() []chromedp.ExecAllocatorOption {
+	width, height := 800, 800
+	if o.WindowWidth > 0 && o.WindowHeight > 0 {
+		width, height = o.WindowWidth, o.WindowHeight
+	}
+	opts := []chromedp.ExecAllocatorOption{
+		chromedp.WindowSize(width, height),
+	}
+
+	if o.ExecPath != "" {
+		opts = append(opts, chromedp.ExecPath(o.ExecPath))
+	}
+	if o.Proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(o.Proxy))
+	}
+	if o.Lang != "" {
+		opts = append(opts, chromedp.Flag("lang", o.Lang), chromedp.Flag("accept-lang", o.Lang))
+	}
+	if o.IgnoreCertErrors {
+		opts = append(opts, chromedp.IgnoreCertErrors)
+	}
+	for _, f := range o.Flags {
+		name, value := parseBrowserFlag(f)
+		if name != "" {
+			opts = append(opts, chromedp.Flag(name, value))
+		}
+	}
+
+	return opts
+}
+
+// parseBrowserFlag parses a command line flag for the browser like "--name=value" or "--name".
+func parseBrowserFlag(s string) (name string, value any) {
+	s = strings.TrimLeft(strings.TrimSpace(s), "-")
+	if i := strings.Index(s, "="); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, true
+}
+
+// ParseWindowSize parses a window size string like "1280x720".
+func ParseWindowSize(s string) (width, height int, err error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(s), "x")
+	if ok {
+		width, err = strconv.Atoi(strings.TrimSpace(ws))
+		if err == nil {
+			height, err = strconv.Atoi(strings.TrimSpace(hs))
+		}
+	}
+	if !ok || err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid window size: %q", s)
+	}
+	return width, height, nil
+}
+
+func envBool(s string) bool {
+	switch strings.ToLower(s) {
+	case "", "0", "false", "no", "off":
+		return false
+	default:
+		return true
+	}
+}
+
+// BrowserOptionsFromEnv reads browser options from environment variables.
+func BrowserOptionsFromEnv(getenv func(string) string) (BrowserOptions, error) {
+	o := BrowserOptions{
+		ExecPath: getenv("WEBSCENARIO_BROWSER_PATH"),
+		Proxy:    getenv("WEBSCENARIO_PROXY"),
+		Lang:     getenv("WEBSCENARIO_LANG"),
+		Flags:    strings.Fields(getenv("WEBSCENARIO_BROWSER_FLAGS")),
+	}
+
+	o.IgnoreCertErrors = envBool(getenv("WEBSCENARIO_IGNORE_CERTIFICATE_ERRORS"))
+
+	if s := getenv("WEBSCENARIO_WINDOW_SIZE"); s != "" {
+		var err error
+		o.WindowWidth, o.WindowHeight, err = ParseWindowSize(s)
+		if err != nil {
+			return o, fmt.Errorf("WEBSCENARIO_WINDOW_SIZE: %w", err)
+		}
+	}
+
+	return o, nil
+}
+
+// readBrowserHeader reads the body of the browser block at the head of a scenario.
+// The block looks like below, and it can be placed after the shebang and comment lines.
+//
+//	--[[browser
+//	proxy = "http://proxy.example.com:8080"
+//	]]
+func readBrowserHeader(r io.Reader) string {
+	s := bufio.NewScanner(r)
+
+	var body []string
+	inBlock := false
+	for first := true; s.Scan(); first = false {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if inBlock {
+			if before, _, ok := strings.Cut(line, "]]"); ok {
+				return strings.Join(append(body, before), "\n")
+			}
+			body = append(body, line)
+			continue
+		}
+
+		switch {
+		case first && strings.HasPrefix(line, "#!"):
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "--[["):
+			rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "--[["))
+			if !strings.HasPrefix(rest, "browser") {
+				return ""
+			}
+			rest = strings.TrimPrefix(rest, "browser")
+			if before, _, ok := strings.Cut(rest, "]]"); ok {
+				return before
+			}
+			body = append(body, rest)
+			inBlock = true
+		case strings.HasPrefix(trimmed, "--"):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// ParseBrowserHeader parses the browser block at the head of a scenario.
+// The body of the block is evaluated as Lua without any libraries, and the global variables are used as options.
+func ParseBrowserHeader(r io.Reader) (BrowserOptions, error) {
+	var o BrowserOptions
+
+	body := readBrowserHeader(r)
+	if strings.TrimSpace(body) == "" {
+		return o, nil
+	}
+
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+
+	if err := L.DoString(body); err != nil {
+		return o, err
+	}
+
+	str := func(name string, dst *string) error {
+		switch v := L.GetGlobal(name).(type) {
+		case *lua.LNilType:
+		case lua.LString:
+			*dst = string(v)
+		default:
+			return fmt.Errorf("%s: expected string but got %s", name, v.Type())
+		}
+		return nil
+	}
+	num := func(name string, dst *int) error {
+		switch v := L.GetGlobal(name).(type) {
+		case *lua.LNilType:
+		case lua.LNumber:
+			*dst = int(v)
+		default:
+			return fmt.Errorf("%s: expected number but got %s", name, v.Type())
+		}
+		return nil
+	}
+
+	for name, dst := range map[string]*string{"executable": &o.ExecPath, "proxy": &o.Proxy, "lang": &o.Lang} {
+		if err := str(name, dst); err != nil {
+			return o, err
+		}
+	}
+	for name, dst := range map[string]*int{"width": &o.WindowWidth, "height": &o.WindowHeight} {
+		if err := num(name, dst); err != nil {
+			return o, err
+		}
+	}
+
+	switch v := L.GetGlobal("ignoreCertificateErrors").(type) {
+	case *lua.LNilType:
+	case lua.LBool:
+		o.IgnoreCertErrors = bool(v)
+	default:
+		return o, fmt.Errorf("ignoreCertificateErrors: expected boolean but got %s", v.Type())
+	}
+
+	switch v := L.GetGlobal("flags").(type) {
+	case *lua.LNilType:
+	case *lua.LTable:
+		var err error
+		ipairs(v, func(_, f lua.LValue) {
+			if s, ok := f.(lua.LString); ok {
+				o.Flags = append(o.Flags, string(s))
+			} else if err == nil {
+				err = fmt.Errorf("flags: expected list of string but got %s", f.Type())
+			}
+		})
+		if err != nil {
+			return o, err
+		}
+	default:
+		return o, fmt.Errorf("flags: expected table but got %s", v.Type())
+	}
+
+	return o, nil
+}
+
+// LoadBrowserOptions reads browser options from the scenario header and the environment variables, and the evidence flag from the environment variable, and merges them into arg.
+// The options in the arguments have the highest priority, and the scenario header has the lowest.
+func LoadBrowserOptions(arg Arg) (Arg, error) {
+	var header BrowserOptions
+	if arg.Mode != "repl" && arg.Mode != "stdin" {
+		f, err := os.Open(arg.Path())
+		if err == nil {
+			header, err = ParseBrowserHeader(f)
+			f.Close()
+			if err != nil {
+				return arg, fmt.Errorf("%s: browser block: %w", arg.Path(), err)
+			}
+		}
+	}
+
+	env, err := BrowserOptionsFromEnv(os.Getenv)
+	if err != nil {
+		return arg, err
+	}
+
+	arg.Browser = header.Merge(env).Merge(arg.Browser)
+	arg.Evidence = arg.Evidence || envBool(os.Getenv("WEBSCENARIO_EVIDENCE"))
+
+	// These options are to launch a browser, so they don't work with a running browser.
+	if arg.BrowserURL != "" {
+		if arg.UserDataDir != "" {
+			return arg, errors.New("user data directory can not be used with browser URL")
+		}
+		if !arg.Browser.IsZero() {
+			return arg, errors.New("browser options can not be used with browser URL")
+		}
+	}
+
+	return arg, nil
+}
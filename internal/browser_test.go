@@ -0,0 +1,234 @@
+package webscenario
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBrowserOptions_Merge(t *testing.T) {
+	a := BrowserOptions{
+		ExecPath:     "/usr/bin/chromium",
+		Proxy:        "http://a.example.com",
+		WindowWidth:  1280,
+		WindowHeight: 720,
+		Flags:        []string{"--foo"},
+	}
+	b := BrowserOptions{
+		Proxy:            "http://b.example.com",
+		Lang:             "ja-JP",
+		IgnoreCertErrors: true,
+		Flags:            []string{"--bar=baz"},
+	}
+
+	want := BrowserOptions{
+		ExecPath:         "/usr/bin/chromium",
+		Proxy:            "http://b.example.com",
+		Lang:             "ja-JP",
+		IgnoreCertErrors: true,
+		WindowWidth:      1280,
+		WindowHeight:     720,
+		Flags:            []string{"--foo", "--bar=baz"},
+	}
+	if diff := cmp.Diff(want, a.Merge(b)); diff != "" {
+		t.Errorf("unexpected result:\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string{"--foo"}, a.Flags); diff != "" {
+		t.Errorf("original options should not be changed:\n%s", diff)
+	}
+}
+
+func Test_parseBrowserFlag(t *testing.T) {
+	tests := []struct {
+		Input string
+		Name  string
+		Value any
+	}{
+		{"--disable-gpu", "disable-gpu", true},
+		{"disable-gpu", "disable-gpu", true},
+		{"--user-agent=hello world", "user-agent", "hello world"},
+		{"--empty=", "empty", ""},
+	}
+
+	for _, tt := range tests {
+		name, value := parseBrowserFlag(tt.Input)
+		if name != tt.Name || value != tt.Value {
+			t.Errorf("%q: expected %q=%v but got %q=%v", tt.Input, tt.Name, tt.Value, name, value)
+		}
+	}
+}
+
+func TestParseWindowSize(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Width  int
+		Height int
+		Error  bool
+	}{
+		{"1280x720", 1280, 720, false},
+		{"800X600", 800, 600, false},
+		{"1280", 0, 0, true},
+		{"0x720", 0, 0, true},
+		{"axb", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		w, h, err := ParseWindowSize(tt.Input)
+		if (err != nil) != tt.Error {
+			t.Errorf("%q: unexpected error: %v", tt.Input, err)
+		} else if w != tt.Width || h != tt.Height {
+			t.Errorf("%q: expected %dx%d but got %dx%d", tt.Input, tt.Width, tt.Height, w, h)
+		}
+	}
+}
+
+func TestBrowserOptionsFromEnv(t *testing.T) {
+	env := map[string]string{
+		"WEBSCENARIO_BROWSER_PATH":              "/opt/chrome",
+		"WEBSCENARIO_PROXY":                     "http://proxy.example.com:8080",
+		"WEBSCENARIO_LANG":                      "en-US",
+		"WEBSCENARIO_IGNORE_CERTIFICATE_ERRORS": "true",
+		"WEBSCENARIO_WINDOW_SIZE":               "1024x768",
+		"WEBSCENARIO_BROWSER_FLAGS":             "--foo --bar=baz",
+	}
+
+	o, err := BrowserOptionsFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := BrowserOptions{
+		ExecPath:         "/opt/chrome",
+		Proxy:            "http://proxy.example.com:8080",
+		Lang:             "en-US",
+		IgnoreCertErrors: true,
+		WindowWidth:      1024,
+		WindowHeight:     768,
+		Flags:            []string{"--foo", "--bar=baz"},
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Errorf("unexpected options:\n%s", diff)
+	}
+
+	env["WEBSCENARIO_WINDOW_SIZE"] = "big"
+	if _, err := BrowserOptionsFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+func TestParseBrowserHeader(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Script string
+		Want   BrowserOptions
+		Error  string
+	}{
+		{
+			"no-header",
+			"t = tab.new()\n--[[browser\nproxy = 'http://example.com'\n]]",
+			BrowserOptions{},
+			"",
+		},
+		{
+			"full",
+			strings.Join([]string{
+				"#!/usr/bin/env ayd-web-scenario-scheme",
+				"-- this is a test",
+				"",
+				"--[[browser",
+				`executable = "/opt/chrome"`,
+				`proxy = "http://proxy.example.com:8080"`,
+				`lang = "ja-JP"`,
+				`ignoreCertificateErrors = true`,
+				`width, height = 1280, 720`,
+				`flags = {"--disable-gpu"}`,
+				"]]",
+				"t = tab.new()",
+			}, "\n"),
+			BrowserOptions{
+				ExecPath:         "/opt/chrome",
+				Proxy:            "http://proxy.example.com:8080",
+				Lang:             "ja-JP",
+				IgnoreCertErrors: true,
+				WindowWidth:      1280,
+				WindowHeight:     720,
+				Flags:            []string{"--disable-gpu"},
+			},
+			"",
+		},
+		{
+			"one-line",
+			`--[[ browser lang = "en-US" ]]`,
+			BrowserOptions{Lang: "en-US"},
+			"",
+		},
+		{
+			"other-comment",
+			"--[[ hello ]]\n--[[browser\nlang = 'en-US'\n]]",
+			BrowserOptions{},
+			"",
+		},
+		{
+			"invalid-type",
+			"--[[browser\nproxy = 123\n]]",
+			BrowserOptions{},
+			"proxy: expected string but got number",
+		},
+		{
+			"invalid-flags",
+			"--[[browser\nflags = {true}\n]]",
+			BrowserOptions{},
+			"flags: expected list of string but got boolean",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			o, err := ParseBrowserHeader(strings.NewReader(tt.Script))
+			if tt.Error != "" {
+				if err == nil || err.Error() != tt.Error {
+					t.Fatalf("expected error %q but got %v", tt.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tt.Want, o); diff != "" {
+				t.Errorf("unexpected options:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadBrowserOptions_browserURL(t *testing.T) {
+	for _, k := range []string{"WEBSCENARIO_BROWSER_PATH", "WEBSCENARIO_PROXY", "WEBSCENARIO_LANG", "WEBSCENARIO_IGNORE_CERTIFICATE_ERRORS", "WEBSCENARIO_WINDOW_SIZE", "WEBSCENARIO_BROWSER_FLAGS"} {
+		t.Setenv(k, "")
+	}
+
+	tests := []struct {
+		Name  string
+		Arg   Arg
+		Error string
+	}{
+		{"no-options", Arg{Mode: "repl", BrowserURL: "ws://127.0.0.1:9222"}, ""},
+		{"user-data-dir", Arg{Mode: "repl", BrowserURL: "ws://127.0.0.1:9222", UserDataDir: "/tmp/profile"}, "user data directory can not be used with browser URL"},
+		{"browser-options", Arg{Mode: "repl", BrowserURL: "ws://127.0.0.1:9222", Browser: BrowserOptions{Proxy: "http://proxy.example.com"}}, "browser options can not be used with browser URL"},
+		{"local-browser", Arg{Mode: "repl", UserDataDir: "/tmp/profile", Browser: BrowserOptions{Proxy: "http://proxy.example.com"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := LoadBrowserOptions(tt.Arg)
+			if tt.Error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			} else if err == nil || err.Error() != tt.Error {
+				t.Errorf("expected error %q but got %v", tt.Error, err)
+			}
+		})
+	}
+}
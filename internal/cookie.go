@@ -0,0 +1,145 @@
+package webscenario
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/yuin/gopher-lua"
+)
+
+func PackCookie(L *lua.LState, c *http.Cookie) *lua.LTable {
+	l := L.NewTable()
+	L.SetField(l, "name", lua.LString(c.Name))
+	L.SetField(l, "value", lua.LString(c.Value))
+	L.SetField(l, "path", lua.LString(c.Path))
+	L.SetField(l, "domain", lua.LString(c.Domain))
+	if !c.Expires.IsZero() {
+		L.SetField(l, "expires", lua.LNumber(c.Expires.UnixMilli()))
+	}
+	L.SetField(l, "secure", lua.LBool(c.Secure))
+	L.SetField(l, "httponly", lua.LBool(c.HttpOnly))
+
+	var samesite string
+	switch c.SameSite {
+	case http.SameSiteDefaultMode:
+		samesite = "default"
+	case http.SameSiteLaxMode:
+		samesite = "lax"
+	case http.SameSiteStrictMode:
+		samesite = "strict"
+	case http.SameSiteNoneMode:
+		samesite = "none"
+	}
+	L.SetField(l, "samesite", lua.LString(samesite))
+
+	return l
+}
+
+func UnpackCookie(L *lua.LState, tbl *lua.LTable) *http.Cookie {
+	c := &http.Cookie{
+		Name:     lua.LVAsString(L.GetField(tbl, "name")),
+		Value:    lua.LVAsString(L.GetField(tbl, "value")),
+		Secure:   lua.LVAsBool(L.GetField(tbl, "secure")),
+		HttpOnly: lua.LVAsBool(L.GetField(tbl, "httponly")),
+	}
+	if s, ok := L.GetField(tbl, "path").(lua.LString); ok {
+		c.Path = string(s)
+	}
+	if s, ok := L.GetField(tbl, "domain").(lua.LString); ok {
+		c.Domain = string(s)
+	}
+	if n, ok := L.GetField(tbl, "expires").(lua.LNumber); ok {
+		c.Expires = time.UnixMilli(int64(n))
+	}
+	if s, ok := L.GetField(tbl, "samesite").(lua.LString); ok {
+		switch strings.ToLower(string(s)) {
+		case "default":
+			c.SameSite = http.SameSiteDefaultMode
+		case "lax":
+			c.SameSite = http.SameSiteLaxMode
+		case "strict":
+			c.SameSite = http.SameSiteStrictMode
+		case "none":
+			c.SameSite = http.SameSiteNoneMode
+		}
+	}
+	return c
+}
+
+// CookieFromBrowser converts a cookie in the browser to http.Cookie.
+func CookieFromBrowser(c *network.Cookie) *http.Cookie {
+	h := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Secure:   c.Secure,
+		HttpOnly: c.HTTPOnly,
+	}
+	if !c.Session {
+		h.Expires = time.UnixMilli(int64(c.Expires * 1000))
+	}
+	switch c.SameSite {
+	case network.CookieSameSiteLax:
+		h.SameSite = http.SameSiteLaxMode
+	case network.CookieSameSiteStrict:
+		h.SameSite = http.SameSiteStrictMode
+	case network.CookieSameSiteNone:
+		h.SameSite = http.SameSiteNoneMode
+	}
+	return h
+}
+
+// splitBrowserCookie converts a cookie in the browser to http.Cookie and the URL that the cookie belongs to.
+// The Domain of the returned cookie is empty if the cookie is a host-only cookie.
+func splitBrowserCookie(c *network.Cookie) (*url.URL, *http.Cookie) {
+	u := &url.URL{
+		Scheme: "http",
+		Host:   strings.TrimPrefix(c.Domain, "."),
+		Path:   c.Path,
+	}
+	if c.Secure {
+		u.Scheme = "https"
+	}
+
+	h := CookieFromBrowser(c)
+	if !strings.HasPrefix(c.Domain, ".") {
+		// This is a host-only cookie.
+		h.Domain = ""
+	}
+
+	return u, h
+}
+
+// CookieToBrowser converts http.Cookie to a parameter to set cookie into the browser.
+// The url is used to determine the domain and path if the cookie doesn't have them.
+func CookieToBrowser(c *http.Cookie, url string) *network.CookieParam {
+	p := &network.CookieParam{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HTTPOnly: c.HttpOnly,
+	}
+	if c.Domain == "" {
+		p.URL = url
+	}
+	if !c.Expires.IsZero() {
+		t := cdp.TimeSinceEpoch(c.Expires)
+		p.Expires = &t
+	}
+	switch c.SameSite {
+	case http.SameSiteLaxMode:
+		p.SameSite = network.CookieSameSiteLax
+	case http.SameSiteStrictMode:
+		p.SameSite = network.CookieSameSiteStrict
+	case http.SameSiteNoneMode:
+		p.SameSite = network.CookieSameSiteNone
+	}
+	return p
+}
@@ -0,0 +1,28 @@
+package webscenario
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/chromedp/chromedp/device"
+)
+
+var (
+	deviceOnce  sync.Once
+	deviceTable map[string]device.Info
+)
+
+// LookupDevice finds a device preset by name like "iPhone 13" or "Pixel 5 landscape".
+// The name is case insensitive.
+func LookupDevice(name string) (device.Info, bool) {
+	deviceOnce.Do(func() {
+		deviceTable = make(map[string]device.Info)
+		// device.MotoG4landscape is the last preset in chromedp/device.
+		for d := device.Reset + 1; d <= device.MotoG4landscape; d++ {
+			deviceTable[strings.ToLower(d.String())] = d.Device()
+		}
+	})
+
+	info, ok := deviceTable[strings.ToLower(strings.TrimSpace(name))]
+	return info, ok
+}
@@ -0,0 +1,32 @@
+package webscenario
+
+import (
+	"testing"
+)
+
+func TestLookupDevice(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Width     int64
+		Height    int64
+		Landscape bool
+	}{
+		{"iPhone 13", 390, 844, false},
+		{"iphone 13", 390, 844, false},
+		{"Pixel 5 landscape", 851, 393, true},
+		{"Moto G4 landscape", 640, 360, true},
+	}
+
+	for _, tt := range tests {
+		d, ok := LookupDevice(tt.Name)
+		if !ok {
+			t.Errorf("%q: not found", tt.Name)
+		} else if d.Width != tt.Width || d.Height != tt.Height || d.Landscape != tt.Landscape || !d.Mobile {
+			t.Errorf("%q: unexpected device: %#v", tt.Name, d)
+		}
+	}
+
+	if _, ok := LookupDevice("no such device"); ok {
+		t.Errorf("unknown device should not be found")
+	}
+}
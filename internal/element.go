@@ -13,9 +13,9 @@ import (
 )
 
 type Element struct {
-	name string
-	node *cdp.Node
-	tab  *Tab
+	name  string
+	node  *cdp.Node
+	frame *Frame
 }
 
 func nodeAction(sel interface{}, node **cdp.Node, opts ...chromedp.QueryOption) chromedp.QueryAction {
@@ -25,15 +25,17 @@ func nodeAction(sel interface{}, node **cdp.Node, opts ...chromedp.QueryOption)
 	}, opts...)
 }
 
-func NewElement(L *lua.LState, t *Tab, query string) Element {
+func NewElement(L *lua.LState, f *Frame, query string) Element {
 	var node *cdp.Node
-	name := fmt.Sprintf("$(%q)", strings.TrimSpace(query))
-	t.RunSelector(L, name, nodeAction(query, &node, chromedp.ByQuery))
+	name := fmt.Sprintf("%s(%q)", f.name, strings.TrimSpace(query))
+	f.RunSelector(L, name, f.Query(func(opts ...chromedp.QueryOption) chromedp.Action {
+		return nodeAction(query, &node, append(opts, chromedp.ByQuery)...)
+	}))
 
 	return Element{
-		name: name,
-		node: node,
-		tab:  t,
+		name:  name,
+		node:  node,
+		frame: f,
 	}
 }
 
@@ -44,13 +46,13 @@ func (e Element) ToLua(L *lua.LState) *lua.LUserData {
 	return ud
 }
 
-func newElementsTableFromNodes(L *lua.LState, t *Tab, name string, nodes []*cdp.Node) *lua.LTable {
+func newElementsTableFromNodes(L *lua.LState, f *Frame, name string, nodes []*cdp.Node) *lua.LTable {
 	tbl := L.NewTable()
 	for _, node := range nodes {
 		tbl.Append(Element{
-			name: name,
-			node: node,
-			tab:  t,
+			name:  name,
+			node:  node,
+			frame: f,
 		}.ToLua(L))
 	}
 
@@ -70,22 +72,26 @@ func newElementsTableFromNodes(L *lua.LState, t *Tab, name string, nodes []*cdp.
 	return tbl
 }
 
-func NewElementsTable(L *lua.LState, t *Tab, query string) *lua.LTable {
+func NewElementsTable(L *lua.LState, f *Frame, query string) *lua.LTable {
 	var nodes []*cdp.Node
-	name := fmt.Sprintf("$:all(%q)", strings.TrimSpace(query))
-	t.RunSelector(
+	name := fmt.Sprintf("%s:all(%q)", f.name, strings.TrimSpace(query))
+	f.RunSelector(
 		L,
 		name,
-		chromedp.Nodes(query, &nodes, chromedp.ByQueryAll, chromedp.AtLeast(0)),
+		f.Query(func(opts ...chromedp.QueryOption) chromedp.Action {
+			return chromedp.Nodes(query, &nodes, append(opts, chromedp.ByQueryAll, chromedp.AtLeast(0))...)
+		}),
 	)
-	return newElementsTableFromNodes(L, t, query, nodes)
+	return newElementsTableFromNodes(L, f, query, nodes)
 }
 
-func NewElementsTableByXPath(L *lua.LState, t *Tab, query string) *lua.LTable {
+func NewElementsTableByXPath(L *lua.LState, f *Frame, query string) *lua.LTable {
 	var nodes []*cdp.Node
-	name := fmt.Sprintf("$:xpath(%q)", strings.TrimSpace(query))
-	t.RunSelector(L, name, chromedp.Nodes(query, &nodes, chromedp.BySearch))
-	return newElementsTableFromNodes(L, t, name, nodes)
+	name := fmt.Sprintf("%s:xpath(%q)", f.name, strings.TrimSpace(query))
+	f.RunSelector(L, name, f.Query(func(opts ...chromedp.QueryOption) chromedp.Action {
+		return chromedp.Nodes(query, &nodes, append(opts, f.ByXPath(query))...)
+	}))
+	return newElementsTableFromNodes(L, f, name, nodes)
 }
 
 func CheckElement(L *lua.LState) Element {
@@ -107,7 +113,7 @@ func (e Element) Select(L *lua.LState, query string) Element {
 	name := fmt.Sprintf("%s(%q)", e.name, strings.TrimSpace(query))
 
 	var node *cdp.Node
-	e.tab.Run(
+	e.frame.Run(
 		L,
 		name,
 		false,
@@ -116,9 +122,9 @@ func (e Element) Select(L *lua.LState, query string) Element {
 	)
 
 	return Element{
-		name: name,
-		node: node,
-		tab:  e.tab,
+		name:  name,
+		node:  node,
+		frame: e.frame,
 	}
 }
 
@@ -127,7 +133,7 @@ func (e Element) SelectAll(L *lua.LState, query string) *lua.LTable {
 
 	var nodes []*cdp.Node
 
-	e.tab.RunSelector(
+	e.frame.RunSelector(
 		L,
 		name,
 		chromedp.Nodes(
@@ -139,7 +145,7 @@ func (e Element) SelectAll(L *lua.LState, query string) *lua.LTable {
 		),
 	)
 
-	return newElementsTableFromNodes(L, e.tab, name, nodes)
+	return newElementsTableFromNodes(L, e.frame, name, nodes)
 }
 
 func (e Element) SendKeys(L *lua.LState) {
@@ -168,18 +174,19 @@ func (e Element) SendKeys(L *lua.LState) {
 		}
 	}
 
-	e.tab.Run(
+	e.frame.RunOnNode(
 		L,
 		fmt.Sprintf("%s:sendKeys(%q)", e.name, text),
-		true,
-		0,
+		e.node,
+		Visible|Enabled,
+		false,
 		chromedp.KeyEventNode(e.node, text, chromedp.KeyModifiers(mod)),
 	)
 }
 
 func (e Element) SetValue(L *lua.LState) {
 	value := L.CheckString(2)
-	e.tab.Run(L, fmt.Sprintf("%s:setValue(%q)", e.name, value), true, 0, chromedp.SetValue(e.ids(), value, chromedp.ByNodeID))
+	e.frame.RunOnNode(L, fmt.Sprintf("%s:setValue(%q)", e.name, value), e.node, Visible|Enabled, false, chromedp.SetValue(e.ids(), value, chromedp.ByNodeID))
 }
 
 func (e Element) Click(L *lua.LState) {
@@ -192,61 +199,75 @@ func (e Element) Click(L *lua.LState) {
 		name = fmt.Sprintf("%s:click(%q)", e.name, button)
 	}
 
-	e.tab.Run(L, name, true, 0, chromedp.MouseClickNode(e.node, chromedp.Button(button)))
+	e.frame.RunOnNode(L, name, e.node, Visible|Enabled|Stable, true, chromedp.MouseClickNode(e.node, chromedp.Button(button)))
 }
 
 func (e Element) Submit(L *lua.LState) {
-	e.tab.Run(L, fmt.Sprintf("%s:submit()", e.name), true, 0, chromedp.Submit(e.ids(), chromedp.ByNodeID))
+	e.frame.RunOnNode(L, fmt.Sprintf("%s:submit()", e.name), e.node, 0, false, chromedp.Submit(e.ids(), chromedp.ByNodeID))
 }
 
 func (e Element) Focus(L *lua.LState) {
-	e.tab.Run(L, fmt.Sprintf("%s:focus()", e.name), false, 0, chromedp.Focus(e.ids(), chromedp.ByNodeID))
+	e.frame.Run(L, fmt.Sprintf("%s:focus()", e.name), false, 0, chromedp.Focus(e.ids(), chromedp.ByNodeID))
 }
 
 func (e Element) Blur(L *lua.LState) {
-	e.tab.Run(L, fmt.Sprintf("%s:blur()", e.name), false, 0, chromedp.Blur(e.ids(), chromedp.ByNodeID))
+	e.frame.Run(L, fmt.Sprintf("%s:blur()", e.name), false, 0, chromedp.Blur(e.ids(), chromedp.ByNodeID))
 }
 
-func (e Element) Screenshot(L *lua.LState) {
-	name := L.ToString(2)
+func (e Element) Screenshot(L *lua.LState) int {
+	name, opts := checkScreenshotArgs(L)
 
 	var buf []byte
-	e.tab.Run(
+	var capture chromedp.Action = chromedp.Screenshot(e.ids(), &buf, chromedp.ByNodeID)
+	ext := ".png"
+	if opts != nil {
+		if opts.FullPage || opts.Clip != nil {
+			L.ArgError(L.GetTop(), "fullPage and clip can not be used for element.")
+		}
+		capture = opts.Capture(&e.node.NodeID, &buf)
+		ext = opts.Ext()
+	}
+
+	var path string
+	e.frame.Run(
 		L,
 		fmt.Sprintf("%s:screenshot(%v)", e.name, name),
 		false,
 		0,
-		chromedp.Screenshot(e.ids(), &buf, chromedp.ByNodeID),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			return e.tab.Save(name, ".png", buf)
+		capture,
+		chromedp.ActionFunc(func(ctx context.Context) (err error) {
+			path, err = e.frame.tab.Save(name, ext, buf)
+			return err
 		}),
 	)
+	L.Push(lua.LString(path))
+	return 1
 }
 
 func (e Element) GetText(L *lua.LState) int {
 	var text string
-	e.tab.Run(L, fmt.Sprintf("%s.text", e.name), false, 0, chromedp.Text(e.ids(), &text, chromedp.ByNodeID))
+	e.frame.Run(L, fmt.Sprintf("%s.text", e.name), false, e.frame.tab.timeout, WaitActionable(e.node.NodeID, 0), chromedp.Text(e.ids(), &text, chromedp.ByNodeID))
 	L.Push(lua.LString(text))
 	return 1
 }
 
 func (e Element) GetInnerHTML(L *lua.LState) int {
 	var html string
-	e.tab.Run(L, fmt.Sprintf("%s.innerHTML", e.name), false, 0, chromedp.InnerHTML(e.ids(), &html, chromedp.ByNodeID))
+	e.frame.Run(L, fmt.Sprintf("%s.innerHTML", e.name), false, e.frame.tab.timeout, WaitActionable(e.node.NodeID, 0), chromedp.InnerHTML(e.ids(), &html, chromedp.ByNodeID))
 	L.Push(lua.LString(html))
 	return 1
 }
 
 func (e Element) GetOuterHTML(L *lua.LState) int {
 	var html string
-	e.tab.Run(L, fmt.Sprintf("%s.outerHTML", e.name), false, 0, chromedp.OuterHTML(e.ids(), &html, chromedp.ByNodeID))
+	e.frame.Run(L, fmt.Sprintf("%s.outerHTML", e.name), false, e.frame.tab.timeout, WaitActionable(e.node.NodeID, 0), chromedp.OuterHTML(e.ids(), &html, chromedp.ByNodeID))
 	L.Push(lua.LString(html))
 	return 1
 }
 
 func (e Element) GetValue(L *lua.LState) int {
 	var value string
-	e.tab.Run(L, fmt.Sprintf("%s.value", e.name), false, 0, chromedp.Value(e.ids(), &value, chromedp.ByNodeID))
+	e.frame.Run(L, fmt.Sprintf("%s.value", e.name), false, e.frame.tab.timeout, WaitActionable(e.node.NodeID, 0), chromedp.Value(e.ids(), &value, chromedp.ByNodeID))
 	L.Push(lua.LString(value))
 	return 1
 }
@@ -256,7 +277,7 @@ func (e Element) GetAttribute(L *lua.LState) int {
 
 	var value string
 	var ok bool
-	e.tab.Run(L, fmt.Sprintf("%s[%q]", e.name, name), false, 0, chromedp.AttributeValue(e.ids(), name, &value, &ok, chromedp.ByNodeID))
+	e.frame.Run(L, fmt.Sprintf("%s[%q]", e.name, name), false, e.frame.tab.timeout, WaitActionable(e.node.NodeID, 0), chromedp.AttributeValue(e.ids(), name, &value, &ok, chromedp.ByNodeID))
 
 	if ok {
 		L.Push(lua.LString(value))
@@ -274,6 +295,11 @@ func RegisterElementType(ctx context.Context, L *lua.LState) {
 			return 1
 		})
 	}
+	fret := func(f func(Element, *lua.LState) int) *lua.LFunction {
+		return L.NewFunction(func(L *lua.LState) int {
+			return f(CheckElement(L), L)
+		})
+	}
 
 	methods := map[string]*lua.LFunction{
 		"all": L.NewFunction(func(L *lua.LState) int {
@@ -288,7 +314,7 @@ func RegisterElementType(ctx context.Context, L *lua.LState) {
 		"submit":     fn(Element.Submit),
 		"focus":      fn(Element.Focus),
 		"blur":       fn(Element.Blur),
-		"screenshot": fn(Element.Screenshot),
+		"screenshot": fret(Element.Screenshot),
 	}
 
 	getters := map[string]func(Element, *lua.LState) int{
@@ -0,0 +1,152 @@
+package webscenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+type Geolocation struct {
+	Latitude  float64
+	Longitude float64
+	Accuracy  float64
+}
+
+// TabEmulation is a set of environment overrides of a tab.
+// The zero value means no override.
+type TabEmulation struct {
+	Geolocation   *Geolocation
+	Timezone      string
+	Locale        string
+	ColorScheme   string
+	ReducedMotion string
+}
+
+// UnpackTabEmulation reads overrides from a Lua table and applies them to base.
+// The field that is nil keeps the value of base, and the field that is false clears the override.
+func UnpackTabEmulation(L *lua.LState, tbl *lua.LTable, base TabEmulation) (TabEmulation, error) {
+	e := base
+
+	switch g := L.GetField(tbl, "geolocation").(type) {
+	case *lua.LNilType:
+	case lua.LBool:
+		if g {
+			return e, errors.New("geolocation field expected table or false.")
+		}
+		e.Geolocation = nil
+	case *lua.LTable:
+		lat, ok1 := L.GetField(g, "latitude").(lua.LNumber)
+		lng, ok2 := L.GetField(g, "longitude").(lua.LNumber)
+		if !ok1 || !ok2 {
+			return e, errors.New("geolocation field requires latitude and longitude.")
+		}
+		acc, ok := L.GetField(g, "accuracy").(lua.LNumber)
+		if !ok {
+			acc = 1
+		}
+		e.Geolocation = &Geolocation{float64(lat), float64(lng), float64(acc)}
+	default:
+		return e, errors.New("geolocation field expected table or false.")
+	}
+
+	str := func(name string, dst *string, allowed ...string) error {
+		switch v := L.GetField(tbl, name).(type) {
+		case *lua.LNilType:
+		case lua.LBool:
+			if v {
+				return fmt.Errorf("%s field expected string or false.", name)
+			}
+			*dst = ""
+		case lua.LString:
+			if len(allowed) > 0 {
+				ok := false
+				for _, a := range allowed {
+					ok = ok || string(v) == a
+				}
+				if !ok {
+					return fmt.Errorf("%s field expected one of %q.", name, allowed)
+				}
+			}
+			*dst = string(v)
+		default:
+			return fmt.Errorf("%s field expected string or false.", name)
+		}
+		return nil
+	}
+
+	if err := str("timezone", &e.Timezone); err != nil {
+		return e, err
+	}
+	if err := str("locale", &e.Locale); err != nil {
+		return e, err
+	}
+	if err := str("colorScheme", &e.ColorScheme, "light", "dark", "no-preference"); err != nil {
+		return e, err
+	}
+	if err := str("reducedMotion", &e.ReducedMotion, "reduce", "no-preference"); err != nil {
+		return e, err
+	}
+
+	return e, nil
+}
+
+// userAgentOverride makes an action to set User-Agent and Accept-Language.
+// If userAgent is empty, it uses the browser's default.
+func userAgentOverride(userAgent, locale string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		ua := userAgent
+		if ua == "" {
+			var err error
+			if _, _, _, ua, _, err = browser.GetVersion().Do(ctx); err != nil {
+				return err
+			}
+		}
+		return emulation.SetUserAgentOverride(ua).WithAcceptLanguage(locale).Do(ctx)
+	})
+}
+
+// Actions makes actions to change overrides from prev to e.
+// Only changed overrides are applied, because some overrides can't be set twice in the same renderer.
+func (e TabEmulation) Actions(prev TabEmulation, userAgent string) []chromedp.Action {
+	var actions []chromedp.Action
+
+	switch {
+	case e.Geolocation != nil && (prev.Geolocation == nil || *e.Geolocation != *prev.Geolocation):
+		actions = append(
+			actions,
+			browser.GrantPermissions([]browser.PermissionType{browser.PermissionTypeGeolocation}),
+			emulation.SetGeolocationOverride().
+				WithLatitude(e.Geolocation.Latitude).
+				WithLongitude(e.Geolocation.Longitude).
+				WithAccuracy(e.Geolocation.Accuracy),
+		)
+	case e.Geolocation == nil && prev.Geolocation != nil:
+		actions = append(actions, emulation.ClearGeolocationOverride())
+	}
+
+	if e.Timezone != prev.Timezone {
+		actions = append(actions, emulation.SetTimezoneOverride(e.Timezone))
+	}
+
+	if e.Locale != prev.Locale {
+		if e.Locale == "" {
+			actions = append(actions, emulation.SetLocaleOverride(), emulation.SetUserAgentOverride(userAgent))
+		} else {
+			actions = append(actions, emulation.SetLocaleOverride().WithLocale(e.Locale), userAgentOverride(userAgent, e.Locale))
+		}
+	}
+
+	if e.ColorScheme != prev.ColorScheme || e.ReducedMotion != prev.ReducedMotion {
+		actions = append(actions, emulation.SetEmulatedMedia().WithFeatures([]*emulation.MediaFeature{
+			{Name: "prefers-color-scheme", Value: e.ColorScheme},
+			{Name: "prefers-reduced-motion", Value: e.ReducedMotion},
+		}))
+	}
+
+	return actions
+}
@@ -0,0 +1,99 @@
+package webscenario
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/yuin/gopher-lua"
+)
+
+func TestUnpackTabEmulation(t *testing.T) {
+	tests := []struct {
+		Script string
+		Base   TabEmulation
+		Want   TabEmulation
+		Error  string
+	}{
+		{
+			`return {}`,
+			TabEmulation{Timezone: "UTC"},
+			TabEmulation{Timezone: "UTC"},
+			"",
+		},
+		{
+			`return {geolocation={latitude=1.5, longitude=2.5}, timezone="Asia/Tokyo", locale="ja-JP", colorScheme="dark", reducedMotion="reduce"}`,
+			TabEmulation{},
+			TabEmulation{
+				Geolocation:   &Geolocation{1.5, 2.5, 1},
+				Timezone:      "Asia/Tokyo",
+				Locale:        "ja-JP",
+				ColorScheme:   "dark",
+				ReducedMotion: "reduce",
+			},
+			"",
+		},
+		{
+			`return {geolocation=false, timezone=false, colorScheme="light"}`,
+			TabEmulation{Geolocation: &Geolocation{1, 2, 3}, Timezone: "UTC", Locale: "en-US", ColorScheme: "dark"},
+			TabEmulation{Locale: "en-US", ColorScheme: "light"},
+			"",
+		},
+		{
+			`return {colorScheme="blue"}`,
+			TabEmulation{},
+			TabEmulation{},
+			`colorScheme field expected one of ["light" "dark" "no-preference"].`,
+		},
+		{
+			`return {geolocation={latitude=1}}`,
+			TabEmulation{},
+			TabEmulation{},
+			"geolocation field requires latitude and longitude.",
+		},
+		{
+			`return {timezone=true}`,
+			TabEmulation{},
+			TabEmulation{},
+			"timezone field expected string or false.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Script, func(t *testing.T) {
+			L := lua.NewState()
+			defer L.Close()
+
+			if err := L.DoString(tt.Script); err != nil {
+				t.Fatalf("failed to prepare table: %s", err)
+			}
+
+			e, err := UnpackTabEmulation(L, L.CheckTable(-1), tt.Base)
+			if tt.Error != "" {
+				if err == nil || err.Error() != tt.Error {
+					t.Fatalf("expected error %q but got %v", tt.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tt.Want, e); diff != "" {
+				t.Errorf("unexpected result:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTabEmulation_Actions(t *testing.T) {
+	e := TabEmulation{Timezone: "UTC", ColorScheme: "dark"}
+
+	if n := len(e.Actions(e, "")); n != 0 {
+		t.Errorf("expected no actions for no changes but got %d actions", n)
+	}
+	if n := len(e.Actions(TabEmulation{}, "")); n != 2 {
+		t.Errorf("expected 2 actions but got %d actions", n)
+	}
+	if n := len(TabEmulation{}.Actions(TabEmulation{Geolocation: &Geolocation{}}, "")); n != 1 {
+		t.Errorf("expected 1 action to clear geolocation but got %d actions", n)
+	}
+}
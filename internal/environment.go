@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/chromedp/cdproto/cdp"
 	"github.com/yuin/gopher-lua"
 )
 
@@ -21,12 +23,25 @@ type Environment struct {
 	ctx     context.Context
 	stop    context.CancelFunc
 	tabs    []*Tab
+	tabsMu  sync.Mutex
 	logger  *Logger
 	storage *Storage
 	saveWG  sync.WaitGroup
 	errch   chan error
 
-	EnableRecording bool
+	cookiejarCount int
+
+	EnableRecording  bool
+	ScreencastFormat string
+	EnableEvidence   bool
+	FailOnHTTPError  bool
+	evidenceSaved    bool
+
+	// DefaultTimeout is the default timeout of tabs for waiting elements and actions. Zero means no limit.
+	DefaultTimeout time.Duration
+
+	// BrowserContextID is the browser context to open tabs in. Empty means the default browser context.
+	BrowserContextID cdp.BrowserContextID
 }
 
 func NewEnvironment(ctx context.Context, logger *Logger, s *Storage, arg Arg) *Environment {
@@ -42,13 +57,19 @@ func NewEnvironment(ctx context.Context, logger *Logger, s *Storage, arg Arg) *E
 		storage: s,
 		errch:   make(chan error, 1),
 	}
+	if arg.DefaultTimeout > 0 {
+		env.DefaultTimeout = arg.DefaultTimeout
+	}
 	env.Lock()
 
 	RegisterLogger(L, logger)
 	RegisterElementType(ctx, L)
 	RegisterTabType(ctx, env)
+	RegisterFrameType(L)
+	RegisterWebStorageType(L)
 	RegisterTime(ctx, env)
 	RegisterAssert(L)
+	RegisterAssertScreenshot(L)
 	RegisterKey(L)
 	RegisterFileLike(L)
 	RegisterEncodings(env)
@@ -61,7 +82,7 @@ func NewEnvironment(ctx context.Context, logger *Logger, s *Storage, arg Arg) *E
 
 func (env *Environment) Close() error {
 	defer env.Unlock()
-	for _, t := range env.tabs {
+	for _, t := range env.Tabs() {
 		t.Close()
 	}
 	env.lua.Close()
@@ -98,6 +119,8 @@ func (env *Environment) DoFile(path string) error {
 	select {
 	case <-done:
 	case err = <-env.errch:
+		// Save evidence before stop, because tabs will be unavailable after that.
+		env.SaveEvidence()
 		env.stop()
 		<-done
 		ctx, stop := context.WithCancel(env.ctx)
@@ -132,7 +155,14 @@ func (env *Environment) CallEventHandler(f *lua.LFunction, arg *lua.LTable, nret
 
 	L.Push(f)
 	L.Push(arg)
-	env.errch <- L.PCall(1, nret, nil)
+	// Only errors are sent, because DoFile stops the scenario whenever it receives something from errch even if it is nil.
+	// The send doesn't block because the scenario is already stopping if errch is full, and blocking here with the GIL would make a deadlock.
+	if err := L.PCall(1, nret, nil); err != nil {
+		select {
+		case env.errch <- err:
+		default:
+		}
+	}
 
 	var result []lua.LValue
 	for i := 1; i <= nret; i++ {
@@ -184,6 +214,11 @@ func (env *Environment) RegisterNewType(name string, methods map[string]lua.LGFu
 	env.lua.SetGlobal(name, tbl)
 }
 
+func (env *Environment) NewCookieJar() (*CookieJar, error) {
+	env.cookiejarCount++
+	return NewCookieJar(env.cookiejarCount)
+}
+
 func (env *Environment) saveRecord(id int, recorder *Recorder) {
 	env.saveWG.Add(1)
 	go func(id int) {
@@ -191,19 +226,38 @@ func (env *Environment) saveRecord(id int, recorder *Recorder) {
 		if f, err := env.storage.Open(fmt.Sprintf("record%d.gif", id)); err == nil {
 			err = recorder.SaveTo(f)
 			f.Close()
-			if err == NoRecord {
+			if err != nil {
 				env.storage.Remove(f.Name())
 			}
+			if err != nil && err != NoRecord {
+				// The env.ctx is already canceled here, so use another context to report it as a failure rather than abort.
+				env.logger.HandleError(context.Background(), fmt.Errorf("failed to save recording: %w", err))
+			}
 		}
 		env.saveWG.Done()
 	}(id)
 }
 
+func (env *Environment) saveScreencast(recorder *ScreencastRecorder) {
+	env.saveWG.Add(1)
+	go func() {
+		<-recorder.Done
+		if err := recorder.Save(); err != nil && err != NoRecord {
+			env.logger.HandleError(context.Background(), fmt.Errorf("failed to save recording: %w", err))
+		}
+		env.saveWG.Done()
+	}()
+}
+
 func (env *Environment) registerTab(t *Tab) {
+	env.tabsMu.Lock()
+	defer env.tabsMu.Unlock()
 	env.tabs = append(env.tabs, t)
 }
 
 func (env *Environment) unregisterTab(t *Tab) {
+	env.tabsMu.Lock()
+	defer env.tabsMu.Unlock()
 	tabs := make([]*Tab, 0, len(env.tabs))
 	for _, x := range env.tabs {
 		if x != t {
@@ -213,8 +267,15 @@ func (env *Environment) unregisterTab(t *Tab) {
 	env.tabs = tabs
 }
 
+// Tabs returns a snapshot of open tabs.
+func (env *Environment) Tabs() []*Tab {
+	env.tabsMu.Lock()
+	defer env.tabsMu.Unlock()
+	return append(make([]*Tab, 0, len(env.tabs)), env.tabs...)
+}
+
 func (env *Environment) RecordOnAllTabs(L *lua.LState, taskName string) {
-	for _, tab := range env.tabs {
+	for _, tab := range env.Tabs() {
 		tab.RecordOnce(L, taskName)
 	}
 }
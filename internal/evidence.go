@@ -0,0 +1,147 @@
+package webscenario
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// ActivityLog keeps recent console messages and network activities of a tab as text lines.
+// It is used as a part of failure evidence.
+type ActivityLog struct {
+	sync.Mutex
+
+	size  int
+	lines []string
+}
+
+func NewActivityLog(size int) *ActivityLog {
+	return &ActivityLog{size: size}
+}
+
+// Addf adds a line. The oldest line will be dropped if the log is full.
+func (l *ActivityLog) Addf(format string, args ...any) {
+	l.Lock()
+	defer l.Unlock()
+
+	line := time.Now().Format("15:04:05.000 ") + fmt.Sprintf(format, args...)
+	l.lines = append(l.lines, strings.ReplaceAll(line, "\n", "\n\t"))
+	if len(l.lines) > l.size {
+		l.lines = l.lines[len(l.lines)-l.size:]
+	}
+}
+
+func (l *ActivityLog) String() string {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.lines) == 0 {
+		return ""
+	}
+	return strings.Join(l.lines, "\n") + "\n"
+}
+
+// formatRemoteObject makes a string like the browser's console shows.
+func formatRemoteObject(o *runtime.RemoteObject) string {
+	if o.Type == runtime.TypeString {
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err == nil {
+			return s
+		}
+	}
+	if o.UnserializableValue != "" {
+		return string(o.UnserializableValue)
+	}
+	if o.Description != "" {
+		return o.Description
+	}
+	if len(o.Value) > 0 {
+		return string(o.Value)
+	}
+	return o.Type.String()
+}
+
+// consoleArgValue converts an argument of console API into a Go value without calling the browser.
+// The object that is not serialized such as DOM element is represented by its description.
+func consoleArgValue(o *runtime.RemoteObject) any {
+	if len(o.Value) > 0 {
+		var v any
+		if err := json.Unmarshal(o.Value, &v); err == nil {
+			return v
+		}
+	}
+	if o.Type == runtime.TypeUndefined {
+		return nil
+	}
+	return formatRemoteObject(o)
+}
+
+// formatConsoleArgs makes a message from the arguments of console API.
+func formatConsoleArgs(args []*runtime.RemoteObject) string {
+	ss := make([]string, len(args))
+	for i, a := range args {
+		ss[i] = formatRemoteObject(a)
+	}
+	return strings.Join(ss, " ")
+}
+
+// formatException makes a message of an uncaught exception.
+func formatException(e *runtime.ExceptionDetails) string {
+	if e.Exception != nil && e.Exception.Description != "" {
+		return e.Exception.Description
+	}
+	if e.Exception != nil {
+		return e.Text + " " + formatRemoteObject(e.Exception)
+	}
+	return e.Text
+}
+
+// SaveEvidence saves a full-page screenshot, the HTML, and the recent activity log of the tab into the storage.
+// It saves as much as possible even if some of them failed.
+func (t *Tab) SaveEvidence() {
+	ctx, cancel := context.WithTimeout(t.ctx, 10*time.Second)
+	defer cancel()
+
+	prefix := fmt.Sprintf("evidence/tab%d", t.id)
+
+	var screenshot []byte
+	if err := chromedp.Run(ctx, chromedp.FullScreenshot(&screenshot, 100)); err == nil {
+		t.Save(prefix, ".png", screenshot)
+	} else {
+		t.activity.Addf("failed to take screenshot: %s", err)
+	}
+
+	var html string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`document.documentElement.outerHTML`, &html)); err == nil {
+		t.Save(prefix, ".html", []byte(html))
+	} else {
+		t.activity.Addf("failed to get HTML: %s", err)
+	}
+
+	t.Save(prefix, ".log", []byte(t.activity.String()))
+}
+
+// SaveEvidence saves evidence of all open tabs.
+// It does nothing if the evidence is disabled or already saved.
+func (env *Environment) SaveEvidence() {
+	if !env.EnableEvidence || env.evidenceSaved {
+		return
+	}
+	env.evidenceSaved = true
+
+	var wg sync.WaitGroup
+	for _, t := range env.Tabs() {
+		wg.Add(1)
+		go func(t *Tab) {
+			t.SaveEvidence()
+			wg.Done()
+		}(t)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,65 @@
+package webscenario
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/runtime"
+)
+
+func TestActivityLog(t *testing.T) {
+	l := NewActivityLog(3)
+
+	if s := l.String(); s != "" {
+		t.Errorf("empty log should be empty string but got %q", s)
+	}
+
+	for i := 1; i <= 5; i++ {
+		l.Addf("line %d", i)
+	}
+	l.Addf("multi\nline")
+
+	lines := strings.Split(strings.TrimSuffix(l.String(), "\n"), "\n")
+	want := []string{"line 4", "line 5", "multi", "\tline"}
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected lines: %q", lines)
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(lines[i], w) {
+			t.Errorf("%d: expected to end with %q but got %q", i, w, lines[i])
+		}
+	}
+}
+
+func Test_formatConsoleArgs(t *testing.T) {
+	args := []*runtime.RemoteObject{
+		{Type: runtime.TypeString, Value: []byte(`"hello"`)},
+		{Type: runtime.TypeNumber, Value: []byte(`42`), Description: "42"},
+		{Type: runtime.TypeNumber, UnserializableValue: "NaN"},
+		{Type: runtime.TypeObject, ClassName: "Object", Description: "Object"},
+		{Type: runtime.TypeUndefined},
+	}
+
+	want := "hello 42 NaN Object undefined"
+	if s := formatConsoleArgs(args); s != want {
+		t.Errorf("expected %q but got %q", want, s)
+	}
+}
+
+func Test_consoleArgValue(t *testing.T) {
+	tests := []struct {
+		Input *runtime.RemoteObject
+		Want  any
+	}{
+		{&runtime.RemoteObject{Type: runtime.TypeString, Value: []byte(`"hello"`)}, "hello"},
+		{&runtime.RemoteObject{Type: runtime.TypeNumber, Value: []byte(`1.5`)}, 1.5},
+		{&runtime.RemoteObject{Type: runtime.TypeUndefined}, nil},
+		{&runtime.RemoteObject{Type: runtime.TypeObject, Subtype: runtime.SubtypeNode, Description: "button"}, "button"},
+	}
+
+	for _, tt := range tests {
+		if v := consoleArgValue(tt.Input); v != tt.Want {
+			t.Errorf("%#v: expected %#v but got %#v", tt.Input, tt.Want, v)
+		}
+	}
+}
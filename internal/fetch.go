@@ -10,8 +10,10 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/chromedp/cdproto/network"
 	"github.com/yuin/gopher-lua"
 )
 
@@ -75,9 +77,12 @@ func PackFetchResponse(env *Environment, L *lua.LState, resp *http.Response, bod
 }
 
 type CookieJar struct {
+	sync.Mutex
+
 	id   int
 	jar  *cookiejar.Jar
 	urls map[string]*url.URL
+	raws map[string]*http.Cookie
 }
 
 func NewCookieJar(id int) (*CookieJar, error) {
@@ -86,6 +91,7 @@ func NewCookieJar(id int) (*CookieJar, error) {
 		id:   id,
 		jar:  jar,
 		urls: make(map[string]*url.URL),
+		raws: make(map[string]*http.Cookie),
 	}, err
 }
 
@@ -104,7 +110,20 @@ func CheckCookieJar(L *lua.LState, n int) *CookieJar {
 }
 
 func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Lock()
+	defer j.Unlock()
+
 	j.urls[u.String()] = u
+
+	// The cookiejar.Jar doesn't report attributes of cookies, so keep them to pass cookies to the browser.
+	for _, c := range cookies {
+		raw := *c
+		if raw.MaxAge > 0 {
+			raw.Expires = time.Now().Add(time.Duration(raw.MaxAge) * time.Second)
+		}
+		j.raws[u.String()+"\x00"+raw.Name] = &raw
+	}
+
 	j.jar.SetCookies(u, cookies)
 }
 
@@ -112,6 +131,46 @@ func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
 	return j.jar.Cookies(u)
 }
 
+func (j *CookieJar) URLs() map[string]*url.URL {
+	j.Lock()
+	defer j.Unlock()
+
+	urls := make(map[string]*url.URL, len(j.urls))
+	for s, u := range j.urls {
+		urls[s] = u
+	}
+	return urls
+}
+
+// ImportFromBrowser stores cookies in the browser into the jar.
+func (j *CookieJar) ImportFromBrowser(cookies []*network.Cookie) {
+	for _, c := range cookies {
+		u, h := splitBrowserCookie(c)
+		j.SetCookies(u, []*http.Cookie{h})
+	}
+}
+
+// ExportToBrowser makes parameters to set cookies in the jar into the browser.
+// It returns an empty slice rather than nil if the jar is empty, because the browser rejects null as the cookies.
+func (j *CookieJar) ExportToBrowser() []*network.CookieParam {
+	ps := []*network.CookieParam{}
+	for s, u := range j.URLs() {
+		for _, c := range j.Cookies(u) {
+			j.Lock()
+			raw, ok := j.raws[s+"\x00"+c.Name]
+			j.Unlock()
+
+			// The cookie that set via other URL will be exported when processing that URL.
+			if ok {
+				x := *raw
+				x.Value = c.Value
+				ps = append(ps, CookieToBrowser(&x, s))
+			}
+		}
+	}
+	return ps
+}
+
 func (j *CookieJar) CookiesAsLua(L *lua.LState, u *url.URL) (*lua.LTable, bool) {
 	cs := j.Cookies(u)
 	if len(cs) == 0 {
@@ -120,31 +179,7 @@ func (j *CookieJar) CookiesAsLua(L *lua.LState, u *url.URL) (*lua.LTable, bool)
 
 	tbl := L.NewTable()
 	for _, c := range cs {
-		l := L.NewTable()
-		L.SetField(l, "name", lua.LString(c.Name))
-		L.SetField(l, "value", lua.LString(c.Value))
-		L.SetField(l, "path", lua.LString(c.Path))
-		L.SetField(l, "domain", lua.LString(c.Domain))
-		if !c.Expires.IsZero() {
-			L.SetField(l, "expires", lua.LNumber(c.Expires.UnixMilli()))
-		}
-		L.SetField(l, "secure", lua.LBool(c.Secure))
-		L.SetField(l, "httponly", lua.LBool(c.HttpOnly))
-
-		var samesite string
-		switch c.SameSite {
-		case http.SameSiteDefaultMode:
-			samesite = "default"
-		case http.SameSiteLaxMode:
-			samesite = "lax"
-		case http.SameSiteStrictMode:
-			samesite = "strict"
-		case http.SameSiteNoneMode:
-			samesite = "none"
-		}
-		L.SetField(l, "samesite", lua.LString(samesite))
-
-		tbl.Append(l)
+		tbl.Append(PackCookie(L, c))
 	}
 	return tbl, true
 }
@@ -163,7 +198,7 @@ func (j *CookieJar) ToLua(L *lua.LState) lua.LValue {
 			j := CheckCookieJar(L, 1)
 
 			tbl := L.NewTable()
-			for s, u := range j.urls {
+			for s, u := range j.URLs() {
 				if cs, ok := j.CookiesAsLua(L, u); ok {
 					L.SetField(tbl, s, cs)
 				}
@@ -190,8 +225,6 @@ func (j *CookieJar) ToLua(L *lua.LState) lua.LValue {
 }
 
 func RegisterFetch(ctx context.Context, env *Environment) {
-	jarID := 1
-
 	env.RegisterFunction("fetch", func(L *lua.LState) int {
 		url := L.CheckString(1)
 		opts := L.OptTable(2, L.NewTable())
@@ -243,11 +276,10 @@ func RegisterFetch(ctx context.Context, env *Environment) {
 		switch s := L.GetField(opts, "cookiejar").(type) {
 		case *lua.LNilType:
 			var err error
-			cookiejar, err = NewCookieJar(jarID)
+			cookiejar, err = env.NewCookieJar()
 			if err != nil {
 				L.RaiseError("failed to prepare session: %s", err)
 			}
-			jarID++
 		case *lua.LUserData:
 			if j, ok := s.Value.(*CookieJar); ok {
 				cookiejar = j
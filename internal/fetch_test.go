@@ -2,8 +2,13 @@ package webscenario
 
 import (
 	"net/http"
+	"net/url"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
 	"github.com/google/go-cmp/cmp"
 	"github.com/yuin/gopher-lua"
 )
@@ -65,3 +70,43 @@ func TestUnpackFetchHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestCookieJar_browser(t *testing.T) {
+	jar, err := NewCookieJar(1)
+	if err != nil {
+		t.Fatalf("failed to prepare cookie jar: %s", err)
+	}
+
+	if ps := jar.ExportToBrowser(); ps == nil || len(ps) != 0 {
+		t.Errorf("expected empty non-nil cookie params but got %#v", ps)
+	}
+
+	jar.ImportFromBrowser([]*network.Cookie{
+		{Name: "host-only", Value: "hello", Domain: "example.com", Path: "/", Session: true},
+		{Name: "domain", Value: "world", Domain: ".example.com", Path: "/foo", Secure: true, HTTPOnly: true, SameSite: network.CookieSameSiteStrict, Expires: 2000000000},
+	})
+
+	u, _ := url.Parse("https://www.example.com/foo/bar")
+	if cs := jar.Cookies(u); len(cs) != 1 || cs[0].Name != "domain" {
+		t.Errorf("unexpected cookies for %s: %v", u, cs)
+	}
+
+	u, _ = url.Parse("http://example.com/")
+	if cs := jar.Cookies(u); len(cs) != 1 || cs[0].Name != "host-only" {
+		t.Errorf("unexpected cookies for %s: %v", u, cs)
+	}
+
+	ps := jar.ExportToBrowser()
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].Name < ps[j].Name
+	})
+
+	expires := cdp.TimeSinceEpoch(time.Unix(2000000000, 0))
+	want := []*network.CookieParam{
+		{Name: "domain", Value: "world", Domain: ".example.com", Path: "/foo", Secure: true, HTTPOnly: true, SameSite: network.CookieSameSiteStrict, Expires: &expires},
+		{Name: "host-only", Value: "hello", URL: "http://example.com/", Path: "/"},
+	}
+	if diff := cmp.Diff(want, ps, cmp.Comparer(func(a, b cdp.TimeSinceEpoch) bool { return a.Time().Equal(b.Time()) })); diff != "" {
+		t.Errorf("unexpected cookie params:\n%s", diff)
+	}
+}
@@ -0,0 +1,511 @@
+package webscenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// Frame is a document in a tab to query elements and evaluate scripts.
+//
+// The main document of the tab and the iframes in a different process (usually cross-origin iframes) are the root of their own target, so they can be handled as the same as a tab.
+// The iframes in the same process share the target with the parent, and queries are run from the document node of the frame.
+type Frame struct {
+	tab    *Tab
+	ctx    context.Context
+	id     cdp.FrameID
+	root   bool
+	name   string
+	parent *Frame
+}
+
+// findFrameFunction finds a frame element by the name attribute or a CSS selector.
+const findFrameFunction = `function(key) {
+	for (const f of this.querySelectorAll("iframe, frame")) {
+		if (f.name === key) {
+			return f;
+		}
+	}
+	try {
+		return this.querySelector(key);
+	} catch (e) {
+		return null;
+	}
+}`
+
+// xpathFunction finds elements by XPath from this node.
+const xpathFunction = `function(query) {
+	const doc = this.ownerDocument || this;
+	const r = doc.evaluate(query, this, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+	return Array.from({length: r.snapshotLength}, (_, i) => r.snapshotItem(i));
+}`
+
+func CheckFrame(L *lua.LState) *Frame {
+	if ud, ok := L.Get(1).(*lua.LUserData); ok {
+		if f, ok := ud.Value.(*Frame); ok {
+			return f
+		}
+	}
+
+	L.ArgError(1, "frame expected. perhaps you call it like frame.xxx() instead of frame:xxx().")
+	return nil
+}
+
+func (f *Frame) ToLua(L *lua.LState) *lua.LUserData {
+	lf := L.NewUserData()
+	lf.Value = f
+	L.SetMetatable(lf, L.GetTypeMetatable("frame"))
+	return lf
+}
+
+func (f *Frame) Run(L *lua.LState, taskName string, capture bool, timeout time.Duration, action ...chromedp.Action) {
+	f.tab.run(L, f.ctx, taskName, capture, timeout, nil, action...)
+}
+
+// RunOnNode is the same as Run, but waits until the node satisfies the checks before the action, and highlights the node in the recording.
+// The click point is also marked if click is true.
+// The action is bounded by the default timeout of the tab.
+func (f *Frame) RunOnNode(L *lua.LState, taskName string, node *cdp.Node, checks Actionability, click bool, action ...chromedp.Action) {
+	prepare := []chromedp.Action{WaitActionable(node.NodeID, checks)}
+	var target *RecordTarget
+	if f.tab.recorder != nil {
+		target = &RecordTarget{}
+		prepare = append(prepare, target.Locate(node, click), chromedp.ActionFunc(func(ctx context.Context) error {
+			// The position in a frame that has own target is relative to the frame, so it is moved to the position in the tab.
+			if offset, err := f.offset(); err == nil {
+				target.Translate(offset)
+			} else {
+				*target = RecordTarget{}
+			}
+			return nil
+		}))
+	}
+	f.tab.run(L, f.ctx, taskName, true, f.tab.timeout, target, append(prepare, action...)...)
+}
+
+func (f *Frame) RunSelector(L *lua.LState, taskName string, action ...chromedp.Action) {
+	f.tab.env.StartTask(L.Where(1), taskName)
+
+	AsyncRun(f.tab.env, L, func() (struct{}, error) {
+		ctx := f.ctx
+		if f.tab.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, f.tab.timeout)
+			defer cancel()
+		}
+
+		err := chromedp.Run(ctx, action...)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return struct{}{}, errors.New("no such element")
+		}
+		return struct{}{}, err
+	})
+}
+
+// offset gets the position of the viewport of the frame in the viewport of the tab.
+// The frames that share the target with the parent are at the same position as the parent, because the positions in them are relative to the target.
+func (f *Frame) offset() (image.Point, error) {
+	if f.parent == nil {
+		return image.Point{}, nil
+	}
+
+	base, err := f.parent.offset()
+	if err != nil || !f.root {
+		return base, err
+	}
+
+	var box *dom.BoxModel
+	err = chromedp.Run(f.parent.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		backendID, _, err := dom.GetFrameOwner(f.id).Do(ctx)
+		if err != nil {
+			return err
+		}
+		box, err = dom.GetBoxModel().WithBackendNodeID(backendID).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return base, err
+	}
+	return base.Add(quadBounds(box.Content).Min), nil
+}
+
+// document gets the document node of the frame that shares the target with the parent.
+func (f *Frame) document(ctx context.Context) (*cdp.Node, error) {
+	backendID, _, err := dom.GetFrameOwner(f.id).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := dom.DescribeNode().WithBackendNodeID(backendID).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner.ContentDocument == nil {
+		return nil, errors.New("frame is not loaded")
+	}
+	ids, err := dom.PushNodesByBackendIDsToFrontend([]cdp.BackendNodeID{owner.ContentDocument.BackendNodeID}).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cdp.Node{
+		NodeID:        ids[0],
+		BackendNodeID: owner.ContentDocument.BackendNodeID,
+		NodeType:      cdp.NodeTypeDocument,
+		FrameID:       f.id,
+	}, nil
+}
+
+// Query makes a query action in the frame.
+// The build function receives extra options to query in the frame.
+func (f *Frame) Query(build func(opts ...chromedp.QueryOption) chromedp.Action) chromedp.Action {
+	if f.root {
+		return build()
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		doc, err := f.document(ctx)
+		if err != nil {
+			return err
+		}
+		return build(chromedp.FromNode(doc)).Do(ctx)
+	})
+}
+
+// ByXPath returns a query option to select elements by XPath.
+// chromedp.BySearch doesn't support FromNode, so it uses JavaScript for the frames that share the target with the parent.
+func (f *Frame) ByXPath(query string) chromedp.QueryOption {
+	if f.root {
+		return chromedp.BySearch
+	}
+
+	return chromedp.ByFunc(func(ctx context.Context, n *cdp.Node) ([]cdp.NodeID, error) {
+		obj, err := dom.ResolveNode().WithNodeID(n.NodeID).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+
+		var arr *runtime.RemoteObject
+		err = chromedp.CallFunctionOn(xpathFunction, &arr, func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+			return p.WithObjectID(obj.ObjectID)
+		}, query).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer runtime.ReleaseObject(arr.ObjectID).Do(ctx)
+
+		props, _, _, exc, err := runtime.GetProperties(arr.ObjectID).WithOwnProperties(true).Do(ctx)
+		if err != nil {
+			return nil, err
+		} else if exc != nil {
+			return nil, exc
+		}
+
+		var ids []cdp.NodeID
+		for _, p := range props {
+			if p.Value == nil || p.Value.Subtype != "node" {
+				continue
+			}
+			id, err := dom.RequestNode(p.Value.ObjectID).Do(ctx)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	})
+}
+
+// Evaluate makes an action to evaluate the JavaScript expression in the frame.
+func (f *Frame) Evaluate(script string, res any, awaitPromise bool) chromedp.Action {
+	if f.root {
+		return chromedp.Evaluate(script, res, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(awaitPromise)
+		})
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		doc, err := f.document(ctx)
+		if err != nil {
+			return err
+		}
+		obj, err := dom.ResolveNode().WithNodeID(doc.NodeID).Do(ctx)
+		if err != nil {
+			return err
+		}
+		defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+
+		return chromedp.CallFunctionOn(`function(s) { return this.defaultView.eval(s); }`, res, func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+			return p.WithObjectID(obj.ObjectID).WithAwaitPromise(awaitPromise)
+		}, script).Do(ctx)
+	})
+}
+
+// findFrame finds a child frame by the name or the CSS selector.
+// It returns nil if the frame is not found or not loaded yet.
+func (f *Frame) findFrame(ctx context.Context, key string) (*Frame, error) {
+	var owner *runtime.RemoteObject
+	err := f.Evaluate(fmt.Sprintf("(%s).call(document, %s)", findFrameFunction, jsString(key)), &owner, false).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner.ObjectID == "" {
+		return nil, nil
+	}
+	defer runtime.ReleaseObject(owner.ObjectID).Do(ctx)
+
+	node, err := dom.DescribeNode().WithObjectID(owner.ObjectID).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if node.FrameID == "" {
+		return nil, fmt.Errorf("%s is not a frame", strings.ToLower(node.NodeName))
+	}
+
+	if node.ContentDocument != nil {
+		return &Frame{tab: f.tab, ctx: f.ctx, id: node.FrameID, parent: f}, nil
+	}
+
+	targets, err := chromedp.Targets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range targets {
+		if t.Type == "iframe" && t.TargetID == target.ID(node.FrameID) {
+			ctx, err := f.tab.frameContext(t.TargetID)
+			if err != nil {
+				return nil, err
+			}
+			return &Frame{tab: f.tab, ctx: ctx, id: node.FrameID, root: true, parent: f}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *Frame) Frame(L *lua.LState) int {
+	key := L.CheckString(2)
+	name := fmt.Sprintf("%s:frame(%q)", f.name, key)
+
+	var child *Frame
+	f.RunSelector(L, name, chromedp.ActionFunc(func(ctx context.Context) error {
+		for {
+			c, err := f.findFrame(ctx, key)
+			if err != nil {
+				return err
+			} else if c != nil {
+				child = c
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(DefaultPollingInterval):
+			}
+		}
+	}))
+
+	child.name = name
+	L.Push(child.ToLua(L))
+	return 1
+}
+
+func (f *Frame) Wait(L *lua.LState) {
+	query := L.CheckString(2)
+	timeout := f.tab.OptTimeout(L, 3)
+
+	f.Run(L, fmt.Sprintf("%s:wait(%q)", f.name, query), true, timeout, f.Query(func(opts ...chromedp.QueryOption) chromedp.Action {
+		return chromedp.WaitReady(query, append(opts, chromedp.ByQuery)...)
+	}))
+}
+
+func (f *Frame) WaitVisible(L *lua.LState) {
+	query := L.CheckString(2)
+	timeout := f.tab.OptTimeout(L, 3)
+
+	f.Run(L, fmt.Sprintf("%s:waitVisible(%q)", f.name, query), true, timeout, f.Query(func(opts ...chromedp.QueryOption) chromedp.Action {
+		return chromedp.WaitVisible(query, append(opts, chromedp.ByQuery)...)
+	}))
+}
+
+func (f *Frame) WaitXPath(L *lua.LState) {
+	query := L.CheckString(2)
+	timeout := f.tab.OptTimeout(L, 3)
+
+	f.Run(L, fmt.Sprintf("%s:waitXPath(%q)", f.name, query), true, timeout, f.Query(func(opts ...chromedp.QueryOption) chromedp.Action {
+		return chromedp.WaitReady(query, append(opts, f.ByXPath(query))...)
+	}))
+}
+
+func (f *Frame) WaitXPathVisible(L *lua.LState) {
+	query := L.CheckString(2)
+	timeout := f.tab.OptTimeout(L, 3)
+
+	f.Run(L, fmt.Sprintf("%s:waitXPathVisible(%q)", f.name, query), true, timeout, f.Query(func(opts ...chromedp.QueryOption) chromedp.Action {
+		return chromedp.WaitVisible(query, append(opts, f.ByXPath(query))...)
+	}))
+}
+
+func (f *Frame) Eval(L *lua.LState) int {
+	script := L.CheckString(2)
+
+	var res any
+	f.Run(L, fmt.Sprintf("%s:eval([[ %s ]])", f.name, script), true, 0, f.Evaluate(script, &res, false))
+	L.Push(PackLValue(L, res))
+	return 1
+}
+
+// frameSession is the context to control the iframe that has own target, and the function to detach from the target.
+type frameSession struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// frameContext returns the context to control the iframe that has own target.
+// The context is kept until the tab closed, to reuse the session.
+func (t *Tab) frameContext(id target.ID) (context.Context, error) {
+	t.frameContextsMu.Lock()
+	defer t.frameContextsMu.Unlock()
+
+	if t.frameContexts == nil {
+		return nil, errors.New("tab is closed")
+	}
+	if s, ok := t.frameContexts[id]; ok {
+		if s.ctx.Err() == nil {
+			return s.ctx, nil
+		}
+		s.cancel()
+	}
+
+	// The context is cancelled by Tab.Close.
+	ctx, cancel := chromedp.NewContext(t.ctx, chromedp.WithTargetID(id))
+
+	// The first Run attaches to the target, and the session lives while the context of the first Run.
+	// So it should be called here without timeout.
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	t.frameContexts[id] = frameSession{ctx, cancel}
+	return ctx, nil
+}
+
+// frameTree gets the tree of frames in the target of ctx.
+// The frame tree of a target doesn't include the iframes in other processes, so they are looked up from iframes and added to the top frame of the target.
+func (t *Tab) frameTree(ctx context.Context, iframes []*target.Info) (*page.FrameTree, error) {
+	tree, err := page.GetFrameTree().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range iframes {
+		if _, _, err := dom.GetFrameOwner(cdp.FrameID(info.TargetID)).Do(ctx); err != nil {
+			// The owner is not in this target.
+			continue
+		}
+		fctx, err := t.frameContext(info.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		var sub *page.FrameTree
+		err = chromedp.Run(fctx, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+			sub, err = t.frameTree(ctx, iframes)
+			return err
+		}))
+		if err != nil {
+			return nil, err
+		}
+		tree.ChildFrames = append(tree.ChildFrames, sub)
+	}
+
+	return tree, nil
+}
+
+func packFrameTree(L *lua.LState, trees []*page.FrameTree) *lua.LTable {
+	tbl := L.NewTable()
+	for _, t := range trees {
+		ft := L.NewTable()
+		L.SetField(ft, "name", lua.LString(t.Frame.Name))
+		L.SetField(ft, "url", lua.LString(t.Frame.URL+t.Frame.URLFragment))
+		L.SetField(ft, "children", packFrameTree(L, t.ChildFrames))
+		tbl.Append(ft)
+	}
+	return tbl
+}
+
+func (t *Tab) GetFrames(L *lua.LState) int {
+	var tree *page.FrameTree
+	t.Run(L, "$.frames", false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		targets, err := chromedp.Targets(ctx)
+		if err != nil {
+			return err
+		}
+		var iframes []*target.Info
+		for _, info := range targets {
+			if info.Type == "iframe" {
+				iframes = append(iframes, info)
+			}
+		}
+
+		tree, err = t.frameTree(ctx, iframes)
+		return err
+	}))
+	L.Push(packFrameTree(L, tree.ChildFrames))
+	return 1
+}
+
+func RegisterFrameType(L *lua.LState) {
+	fn := func(f func(*Frame, *lua.LState)) lua.LGFunction {
+		return func(L *lua.LState) int {
+			f(CheckFrame(L), L)
+			L.Push(L.Get(1))
+			return 1
+		}
+	}
+
+	fret := func(f func(*Frame, *lua.LState) int) lua.LGFunction {
+		return func(L *lua.LState) int {
+			return f(CheckFrame(L), L)
+		}
+	}
+
+	meta := L.NewTypeMetatable("frame")
+	L.SetField(meta, "__index", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"frame":            fret((*Frame).Frame),
+		"wait":             fn((*Frame).Wait),
+		"waitXPath":        fn((*Frame).WaitXPath),
+		"waitVisible":      fn((*Frame).WaitVisible),
+		"waitXPathVisible": fn((*Frame).WaitXPathVisible),
+		"waitFor":          fret((*Frame).WaitFor),
+		"waitText":         fn((*Frame).WaitText),
+		"waitHidden":       fn((*Frame).WaitHidden),
+		"waitGone":         fn((*Frame).WaitGone),
+		"all": func(L *lua.LState) int {
+			L.Push(NewElementsTable(L, CheckFrame(L), L.CheckString(2)))
+			return 1
+		},
+		"xpath": func(L *lua.LState) int {
+			L.Push(NewElementsTableByXPath(L, CheckFrame(L), L.CheckString(2)))
+			return 1
+		},
+		"eval": fret((*Frame).Eval),
+	}))
+	L.SetField(meta, "__call", L.NewFunction(func(L *lua.LState) int {
+		L.Push(NewElement(L, CheckFrame(L), L.CheckString(2)).ToLua(L))
+		return 1
+	}))
+	L.SetField(meta, "__tostring", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(CheckFrame(L).name))
+		return 1
+	}))
+}
@@ -0,0 +1,336 @@
+package webscenario
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// gifScreenSize is the size of the header and the logical screen descriptor.
+	gifScreenSize = 6 + 7
+
+	// gifHeaderSize is the size of gifScreenSize and the loop extension.
+	gifHeaderSize = gifScreenSize + 19
+
+	// lastGifDelay is the delay of the last frame in 1/100 seconds.
+	lastGifDelay = 40
+)
+
+// gifStream writes GIF frames into a temporary file as soon as they are added, so that a long recording doesn't use much memory.
+// The size of the screen is decided at the end, so the header is written by SaveTo.
+type gifStream struct {
+	MaxFrames int
+	MaxBytes  int64
+
+	tmp           *os.File
+	width, height int
+	frames        int
+	size          int64
+	limited       bool
+
+	last      *image.Paletted
+	pending   *image.Paletted
+	pendingAt time.Time
+}
+
+// Add adds a frame that is recorded at the time.
+// The frame is written when the next frame added or Flush called, because the delay is decided by the next frame.
+func (s *gifStream) Add(img *image.Paletted, at time.Time) error {
+	if s.limited {
+		return nil
+	}
+
+	if s.pending != nil {
+		disposal := byte(0)
+		if s.pending.Rect != img.Rect {
+			disposal = 2 // restore to background
+		}
+		if err := s.write(s.pending, gifDelay(at.Sub(s.pendingAt)), disposal); err != nil || s.limited {
+			return err
+		}
+	}
+
+	cur := &image.Paletted{
+		Pix:     append([]uint8(nil), img.Pix...),
+		Stride:  img.Stride,
+		Rect:    img.Rect,
+		Palette: img.Palette,
+	}
+	if s.last != nil && s.last.Rect == img.Rect {
+		compressFrame(s.last, cur)
+	}
+	s.last = img
+	s.pending = cur
+	s.pendingAt = at
+
+	return nil
+}
+
+// Flush writes the pending frame.
+func (s *gifStream) Flush() error {
+	if s.pending == nil || s.limited {
+		return nil
+	}
+	err := s.write(s.pending, lastGifDelay, 0)
+	s.pending = nil
+	return err
+}
+
+func gifDelay(d time.Duration) int {
+	cs := int(d.Round(10*time.Millisecond) / (10 * time.Millisecond))
+	if cs < 2 {
+		// Most viewers treat delays shorter than 2 as 10.
+		return 2
+	}
+	if cs > 0xFFFF {
+		return 0xFFFF
+	}
+	return cs
+}
+
+func (s *gifStream) write(img *image.Paletted, delay int, disposal byte) error {
+	if s.MaxFrames > 0 && s.frames >= s.MaxFrames {
+		s.limited = true
+		return nil
+	}
+
+	buf, err := encodeGIFFrame(img, delay, disposal)
+	if err != nil {
+		return err
+	}
+	if s.MaxBytes > 0 && gifHeaderSize+s.size+int64(len(buf))+1 > s.MaxBytes {
+		s.limited = true
+		return nil
+	}
+
+	if s.tmp == nil {
+		if s.tmp, err = os.CreateTemp("", "ayd-web-scenario-*.gif"); err != nil {
+			return err
+		}
+	}
+	if _, err := s.tmp.Write(buf); err != nil {
+		return err
+	}
+
+	s.frames++
+	s.size += int64(len(buf))
+	if img.Rect.Max.X > s.width {
+		s.width = img.Rect.Max.X
+	}
+	if img.Rect.Max.Y > s.height {
+		s.height = img.Rect.Max.Y
+	}
+	return nil
+}
+
+// SaveTo writes the GIF file that contains all written frames.
+func (s *gifStream) SaveTo(w io.Writer) error {
+	if s.frames == 0 && s.limited {
+		return fmt.Errorf("the first frame exceeds maxBytes (%d bytes)", s.MaxBytes)
+	}
+	if s.frames == 0 {
+		return NoRecord
+	}
+
+	header := []byte("GIF89a")
+	header = binary.LittleEndian.AppendUint16(header, uint16(s.width))
+	header = binary.LittleEndian.AppendUint16(header, uint16(s.height))
+	header = append(header, 0, 0, 0) // no global color table
+	header = append(header, 0x21, 0xFF, 0x0B)
+	header = append(header, "NETSCAPE2.0"...)
+	header = append(header, 0x03, 0x01, 0x00, 0x00, 0x00) // loop forever
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	if _, err := s.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, s.tmp); err != nil {
+		return err
+	}
+
+	_, err := w.Write([]byte{0x3B})
+	return err
+}
+
+// Close removes the temporary file.
+func (s *gifStream) Close() error {
+	if s.tmp == nil {
+		return nil
+	}
+	s.tmp.Close()
+	return os.Remove(s.tmp.Name())
+}
+
+// encodeGIFFrame encodes a frame with a local color table, by the standard encoder.
+// The result doesn't have the header and the trailer, so that frames can be concatenated into a file.
+func encodeGIFFrame(img *image.Paletted, delay int, disposal byte) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:    []*image.Paletted{img},
+		Delay:    []int{delay},
+		Disposal: []byte{disposal},
+		Config: image.Config{
+			Width:  img.Rect.Max.X,
+			Height: img.Rect.Max.Y,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A single frame GIF has neither the global color table nor the loop extension.
+	b := buf.Bytes()
+	return b[gifScreenSize : len(b)-1], nil
+}
+
+func transparentIndex(p color.Palette) int {
+	for i, c := range p {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// compressGif compresses each frame by the previous frame.
+// It compresses from the last frame, because the previous frame should not be compressed when compressing a frame.
+func compressGif(images []*image.Paletted) {
+	for i := len(images) - 1; i > 0; i-- {
+		if images[i].Rect == images[i-1].Rect {
+			compressFrame(images[i-1], images[i])
+		}
+	}
+}
+
+// compressFrame replaces the pixels that are the same color as the previous frame with the transparent color.
+// Both frames should be the same size.
+func compressFrame(prev, cur *image.Paletted) {
+	transparent := transparentIndex(cur.Palette)
+	if transparent < 0 {
+		return
+	}
+
+	rgba := func(p color.Palette) [][4]uint32 {
+		xs := make([][4]uint32, len(p))
+		for i, c := range p {
+			r, g, b, a := c.RGBA()
+			xs[i] = [4]uint32{r, g, b, a}
+		}
+		return xs
+	}
+	pc, cc := rgba(prev.Palette), rgba(cur.Palette)
+
+	width, height := cur.Rect.Dx(), cur.Rect.Dy()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pi, ci := prev.Pix[y*prev.Stride+x], cur.Pix[y*cur.Stride+x]
+			if int(pi) < len(pc) && int(ci) < len(cc) && pc[pi] == cc[ci] {
+				cur.Pix[y*cur.Stride+x] = uint8(transparent)
+			}
+		}
+	}
+}
+
+type colorCount struct {
+	C [3]uint8
+	N int
+}
+
+// medianCut makes a palette of up to n colors that represents the image, using the median cut algorithm.
+// Colors are reduced to 5 bits per channel before splitting to keep it fast.
+func medianCut(img *image.RGBA, n int) color.Palette {
+	hist := make(map[[3]uint8]int)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		off := img.PixOffset(img.Rect.Min.X, y)
+		for x := 0; x < img.Rect.Dx(); x++ {
+			p := img.Pix[off+x*4:]
+			hist[[3]uint8{p[0] >> 3, p[1] >> 3, p[2] >> 3}]++
+		}
+	}
+
+	colors := make([]colorCount, 0, len(hist))
+	for c, n := range hist {
+		colors = append(colors, colorCount{c, n})
+	}
+	less := func(xs []colorCount, ch int) func(i, j int) bool {
+		return func(i, j int) bool {
+			for k := 0; k < 3; k++ {
+				a, b := xs[i].C[(ch+k)%3], xs[j].C[(ch+k)%3]
+				if a != b {
+					return a < b
+				}
+			}
+			return false
+		}
+	}
+	sort.Slice(colors, less(colors, 0))
+
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		// Split the box that has the widest range of a channel.
+		best, bestCh, bestRange := -1, 0, 0
+		for i, b := range boxes {
+			if len(b) < 2 {
+				continue
+			}
+			for ch := 0; ch < 3; ch++ {
+				lo, hi := b[0].C[ch], b[0].C[ch]
+				for _, c := range b {
+					if c.C[ch] < lo {
+						lo = c.C[ch]
+					}
+					if c.C[ch] > hi {
+						hi = c.C[ch]
+					}
+				}
+				if int(hi-lo) > bestRange || best < 0 {
+					best, bestCh, bestRange = i, ch, int(hi-lo)
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		b := boxes[best]
+		sort.Slice(b, less(b, bestCh))
+		total := 0
+		for _, c := range b {
+			total += c.N
+		}
+		mid, sum := 1, b[0].N
+		for mid < len(b)-1 && sum*2 < total {
+			sum += b[mid].N
+			mid++
+		}
+		boxes[best] = b[:mid]
+		boxes = append(boxes, b[mid:])
+	}
+
+	p := make(color.Palette, 0, len(boxes))
+	for _, b := range boxes {
+		var sum [3]int
+		total := 0
+		for _, c := range b {
+			for ch, v := range c.C {
+				sum[ch] += int(v<<3|v>>2) * c.N
+			}
+			total += c.N
+		}
+		if total == 0 {
+			continue
+		}
+		p = append(p, color.RGBA{uint8(sum[0] / total), uint8(sum[1] / total), uint8(sum[2] / total), 255})
+	}
+	return p
+}
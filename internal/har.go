@@ -0,0 +1,480 @@
+package webscenario
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+)
+
+// HAR is an HTTP Archive 1.2.
+// See also http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	ResourceType    string      `json:"_resourceType,omitempty"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int64          `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings is the time of each phase in milliseconds. -1 means that the phase is not applicable.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// harRecord is a request that HARRecorder is observing.
+type harRecord struct {
+	id           network.RequestID
+	started      time.Time
+	wallTime     time.Time
+	finished     time.Time
+	resourceType network.ResourceType
+	request      *network.Request
+	response     *network.Response
+	redirectURL  string
+	encodedSize  float64
+	errorText    string
+	body         []byte
+}
+
+// HARRecorder records network events of a tab to make an HTTP Archive.
+type HARRecorder struct {
+	sync.Mutex
+
+	// WithBody makes the recorder to include response bodies into the archive.
+	WithBody bool
+
+	records []*harRecord
+	current map[network.RequestID]*harRecord
+}
+
+func NewHARRecorder(withBody bool) *HARRecorder {
+	return &HARRecorder{
+		WithBody: withBody,
+		current:  make(map[network.RequestID]*harRecord),
+	}
+}
+
+func monotonicTime(t *cdp.MonotonicTime) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.Time()
+}
+
+// OnRequest handles Network.requestWillBeSent event.
+// A redirect reuses the same request ID, so the previous request is finished with the redirect response.
+func (r *HARRecorder) OnRequest(e *network.EventRequestWillBeSent) {
+	r.Lock()
+	defer r.Unlock()
+
+	if prev, ok := r.current[e.RequestID]; ok && e.RedirectResponse != nil {
+		prev.response = e.RedirectResponse
+		prev.redirectURL = e.Request.URL + e.Request.URLFragment
+		prev.encodedSize = e.RedirectResponse.EncodedDataLength
+		prev.finished = monotonicTime(e.Timestamp)
+	}
+
+	rec := &harRecord{
+		id:           e.RequestID,
+		started:      monotonicTime(e.Timestamp),
+		resourceType: e.Type,
+		request:      e.Request,
+	}
+	if e.WallTime != nil {
+		rec.wallTime = e.WallTime.Time()
+	}
+	r.records = append(r.records, rec)
+	r.current[e.RequestID] = rec
+}
+
+// OnResponse handles Network.responseReceived event.
+func (r *HARRecorder) OnResponse(e *network.EventResponseReceived) {
+	r.Lock()
+	defer r.Unlock()
+
+	if rec, ok := r.current[e.RequestID]; ok {
+		rec.response = e.Response
+		rec.encodedSize = e.Response.EncodedDataLength
+	}
+}
+
+// OnFinished handles Network.loadingFinished event.
+func (r *HARRecorder) OnFinished(e *network.EventLoadingFinished) {
+	r.Lock()
+	defer r.Unlock()
+
+	if rec, ok := r.current[e.RequestID]; ok {
+		rec.finished = monotonicTime(e.Timestamp)
+		rec.encodedSize = e.EncodedDataLength
+	}
+}
+
+// OnFailed handles Network.loadingFailed event.
+func (r *HARRecorder) OnFailed(e *network.EventLoadingFailed) {
+	r.Lock()
+	defer r.Unlock()
+
+	if rec, ok := r.current[e.RequestID]; ok {
+		rec.finished = monotonicTime(e.Timestamp)
+		rec.errorText = e.ErrorText
+		delete(r.current, e.RequestID)
+	}
+}
+
+// FetchBodies gets response bodies that are not fetched yet from the browser.
+// The bodies that are already evicted from the browser are ignored.
+func (r *HARRecorder) FetchBodies(ctx context.Context) error {
+	if !r.WithBody {
+		return nil
+	}
+
+	r.Lock()
+	var targets []*harRecord
+	for _, rec := range r.records {
+		if rec.response != nil && rec.body == nil && rec.redirectURL == "" && rec.errorText == "" && !rec.finished.IsZero() {
+			targets = append(targets, rec)
+		}
+	}
+	r.Unlock()
+
+	for _, rec := range targets {
+		body, err := network.GetResponseBody(rec.id).Do(ctx)
+		var cdperr *cdproto.Error
+		if errors.As(err, &cdperr) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		r.Lock()
+		rec.body = body
+		r.Unlock()
+	}
+	return nil
+}
+
+// HAR makes an HTTP Archive from the recorded requests.
+func (r *HARRecorder) HAR() HAR {
+	r.Lock()
+	defer r.Unlock()
+
+	entries := make([]HAREntry, 0, len(r.records))
+	for _, rec := range r.records {
+		entries = append(entries, rec.Entry())
+	}
+
+	return HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{
+				Name:    "ayd-web-scenario",
+				Version: Version,
+			},
+			Entries: entries,
+		},
+	}
+}
+
+// Bytes makes an HTTP Archive file.
+func (r *HARRecorder) Bytes() ([]byte, error) {
+	return json.MarshalIndent(r.HAR(), "", "  ")
+}
+
+func harHeaders(h network.Headers) []HARNameValue {
+	xs := make([]HARNameValue, 0, len(h))
+	for k, v := range h {
+		// Chrome joins the same headers with a newline.
+		for _, s := range strings.Split(fmt.Sprint(v), "\n") {
+			xs = append(xs, HARNameValue{k, s})
+		}
+	}
+	sort.SliceStable(xs, func(i, j int) bool {
+		return xs[i].Name < xs[j].Name
+	})
+	return xs
+}
+
+func harHeader(h network.Headers, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func harQueryString(rawURL string) []HARNameValue {
+	xs := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return xs
+	}
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			xs = append(xs, HARNameValue{k, v})
+		}
+	}
+	sort.SliceStable(xs, func(i, j int) bool {
+		return xs[i].Name < xs[j].Name
+	})
+	return xs
+}
+
+func harCookies(cs []*http.Cookie) []HARCookie {
+	xs := make([]HARCookie, 0, len(cs))
+	for _, c := range cs {
+		x := HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			x.Expires = &c.Expires
+		}
+		xs = append(xs, x)
+	}
+	return xs
+}
+
+func harHTTPVersion(protocol string) string {
+	switch protocol {
+	case "":
+		return "unknown"
+	case "h2":
+		return "HTTP/2.0"
+	case "h3":
+		return "HTTP/3.0"
+	default:
+		return strings.ToUpper(protocol)
+	}
+}
+
+// harTimings calculates the timings of an entry.
+// The timing is nil if the response came from the cache or something.
+func harTimings(timing *network.ResourceTiming, total float64) HARTimings {
+	if timing == nil {
+		return HARTimings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: 0, Receive: total, SSL: -1}
+	}
+
+	phase := func(start, end float64) float64 {
+		if start < 0 || end < 0 {
+			return -1
+		}
+		return end - start
+	}
+
+	t := HARTimings{
+		Blocked: -1,
+		DNS:     phase(timing.DNSStart, timing.DNSEnd),
+		Connect: phase(timing.ConnectStart, timing.ConnectEnd),
+		SSL:     phase(timing.SslStart, timing.SslEnd),
+		Send:    phase(timing.SendStart, timing.SendEnd),
+		Wait:    phase(timing.SendEnd, timing.ReceiveHeadersEnd),
+	}
+	for _, x := range []float64{timing.DNSStart, timing.ConnectStart, timing.SendStart} {
+		if x >= 0 {
+			t.Blocked = x
+			break
+		}
+	}
+	if t.Send < 0 {
+		t.Send = 0
+	}
+	if t.Wait < 0 {
+		t.Wait = 0
+	}
+	t.Receive = total - timing.ReceiveHeadersEnd
+	if t.Receive < 0 {
+		t.Receive = 0
+	}
+	return t
+}
+
+func (t HARTimings) Total() float64 {
+	total := 0.0
+	// SSL is included in Connect.
+	for _, x := range []float64{t.Blocked, t.DNS, t.Connect, t.Send, t.Wait, t.Receive} {
+		if x > 0 {
+			total += x
+		}
+	}
+	return total
+}
+
+// Entry converts the record into an entry of HTTP Archive.
+func (rec *harRecord) Entry() HAREntry {
+	req := rec.request
+	reqHeaders := req.Headers
+	if rec.response != nil && len(rec.response.RequestHeaders) > 0 {
+		reqHeaders = rec.response.RequestHeaders
+	}
+
+	e := HAREntry{
+		StartedDateTime: rec.wallTime,
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         req.URL + req.URLFragment,
+			HTTPVersion: "unknown",
+			Cookies:     harCookies((&http.Request{Header: http.Header{"Cookie": {harHeader(reqHeaders, "Cookie")}}}).Cookies()),
+			Headers:     harHeaders(reqHeaders),
+			QueryString: harQueryString(req.URL),
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: HARResponse{
+			Cookies:     []HARCookie{},
+			Headers:     []HARNameValue{},
+			HTTPVersion: "unknown",
+			RedirectURL: rec.redirectURL,
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		ResourceType: rec.resourceType.String(),
+		Error:        rec.errorText,
+	}
+
+	if req.HasPostData {
+		e.Request.BodySize = len(req.PostData)
+		e.Request.PostData = &HARPostData{
+			MimeType: harHeader(req.Headers, "Content-Type"),
+			Text:     req.PostData,
+		}
+	}
+
+	total := 0.0
+	if !rec.finished.IsZero() && !rec.started.IsZero() {
+		total = float64(rec.finished.Sub(rec.started)) / float64(time.Millisecond)
+	}
+
+	if res := rec.response; res != nil {
+		e.Request.HTTPVersion = harHTTPVersion(res.Protocol)
+		e.Response.Status = res.Status
+		e.Response.StatusText = res.StatusText
+		e.Response.HTTPVersion = harHTTPVersion(res.Protocol)
+		e.Response.Headers = harHeaders(res.Headers)
+		e.Response.Cookies = harCookies((&http.Response{Header: http.Header{"Set-Cookie": strings.Split(harHeader(res.Headers, "Set-Cookie"), "\n")}}).Cookies())
+		e.Response.BodySize = int(rec.encodedSize)
+		e.Response.Content.MimeType = res.MimeType
+		e.Response.Content.Size = int(rec.encodedSize)
+		if rec.body != nil {
+			e.Response.Content.Size = len(rec.body)
+			if utf8.Valid(rec.body) {
+				e.Response.Content.Text = string(rec.body)
+			} else {
+				e.Response.Content.Text = base64.StdEncoding.EncodeToString(rec.body)
+				e.Response.Content.Encoding = "base64"
+			}
+		}
+		if res.RemoteIPAddress != "" {
+			e.ServerIPAddress = strings.Trim(res.RemoteIPAddress, "[]")
+		}
+		if res.ConnectionID > 0 {
+			e.Connection = fmt.Sprint(res.ConnectionID)
+		}
+
+		if res.Timing != nil && !rec.finished.IsZero() {
+			requestTime := cdp.MonotonicTimeEpoch.Add(time.Duration(res.Timing.RequestTime * float64(time.Second)))
+			total = float64(rec.finished.Sub(requestTime)) / float64(time.Millisecond)
+		}
+		e.Timings = harTimings(res.Timing, total)
+	} else {
+		e.Timings = harTimings(nil, total)
+	}
+
+	e.Time = e.Timings.Total()
+
+	return e
+}
@@ -0,0 +1,140 @@
+package webscenario
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHARRecorder(t *testing.T) {
+	mono := func(sec float64) *cdp.MonotonicTime {
+		x := cdp.MonotonicTime(cdp.MonotonicTimeEpoch.Add(time.Duration(sec * float64(time.Second))))
+		return &x
+	}
+	wall := cdp.TimeSinceEpoch(time.Date(2022, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	r := NewHARRecorder(false)
+
+	r.OnRequest(&network.EventRequestWillBeSent{
+		RequestID: "1",
+		Timestamp: mono(10),
+		WallTime:  &wall,
+		Type:      network.ResourceTypeDocument,
+		Request: &network.Request{
+			URL:     "http://example.com/old?a=1",
+			Method:  "GET",
+			Headers: network.Headers{"Cookie": "hello=world"},
+		},
+	})
+	r.OnRequest(&network.EventRequestWillBeSent{
+		RequestID: "1",
+		Timestamp: mono(10.1),
+		WallTime:  &wall,
+		Type:      network.ResourceTypeDocument,
+		Request: &network.Request{
+			URL:    "http://example.com/new",
+			Method: "GET",
+		},
+		RedirectResponse: &network.Response{
+			Status:     302,
+			StatusText: "Found",
+			Protocol:   "http/1.1",
+			Headers:    network.Headers{"Location": "/new"},
+		},
+	})
+	r.OnResponse(&network.EventResponseReceived{
+		RequestID: "1",
+		Response: &network.Response{
+			Status:          200,
+			StatusText:      "OK",
+			Protocol:        "h2",
+			MimeType:        "text/html",
+			RemoteIPAddress: "[::1]",
+			Headers:         network.Headers{"Set-Cookie": "a=b\nc=d"},
+			Timing: &network.ResourceTiming{
+				RequestTime:       10.1,
+				DNSStart:          -1,
+				DNSEnd:            -1,
+				ConnectStart:      -1,
+				ConnectEnd:        -1,
+				SslStart:          -1,
+				SslEnd:            -1,
+				SendStart:         1,
+				SendEnd:           2,
+				ReceiveHeadersEnd: 52,
+			},
+		},
+	})
+	r.OnFinished(&network.EventLoadingFinished{
+		RequestID:         "1",
+		Timestamp:         mono(10.2),
+		EncodedDataLength: 1234,
+	})
+
+	r.OnRequest(&network.EventRequestWillBeSent{
+		RequestID: "2",
+		Timestamp: mono(11),
+		WallTime:  &wall,
+		Type:      network.ResourceTypeImage,
+		Request: &network.Request{
+			URL:    "http://example.com/image.png",
+			Method: "GET",
+		},
+	})
+	r.OnFailed(&network.EventLoadingFailed{
+		RequestID: "2",
+		Timestamp: mono(11.5),
+		ErrorText: "net::ERR_CONNECTION_REFUSED",
+	})
+
+	har := r.HAR()
+
+	if har.Log.Version != "1.2" {
+		t.Errorf("unexpected version: %q", har.Log.Version)
+	}
+	if len(har.Log.Entries) != 3 {
+		t.Fatalf("unexpected number of entries: %d", len(har.Log.Entries))
+	}
+
+	redirect := har.Log.Entries[0]
+	if redirect.Response.Status != 302 || redirect.Response.RedirectURL != "http://example.com/new" {
+		t.Errorf("unexpected redirect response: %d %q", redirect.Response.Status, redirect.Response.RedirectURL)
+	}
+	if diff := cmp.Diff([]HARNameValue{{"a", "1"}}, redirect.Request.QueryString); diff != "" {
+		t.Errorf("unexpected query string:\n%s", diff)
+	}
+	if diff := cmp.Diff([]HARCookie{{Name: "hello", Value: "world"}}, redirect.Request.Cookies); diff != "" {
+		t.Errorf("unexpected request cookies:\n%s", diff)
+	}
+	if d := redirect.Timings.Receive; d < 99 || 101 < d {
+		t.Errorf("unexpected time of redirect: %f", d)
+	}
+
+	doc := har.Log.Entries[1]
+	if doc.Response.HTTPVersion != "HTTP/2.0" || doc.ServerIPAddress != "::1" || doc.Response.BodySize != 1234 {
+		t.Errorf("unexpected document response: %#v", doc.Response)
+	}
+	if diff := cmp.Diff([]HARCookie{{Name: "a", Value: "b"}, {Name: "c", Value: "d"}}, doc.Response.Cookies); diff != "" {
+		t.Errorf("unexpected response cookies:\n%s", diff)
+	}
+	wantTimings := HARTimings{Blocked: 1, DNS: -1, Connect: -1, SSL: -1, Send: 1, Wait: 50, Receive: 48}
+	if diff := cmp.Diff(wantTimings, doc.Timings, cmp.Comparer(func(x, y float64) bool {
+		return x-y < 0.01 && y-x < 0.01
+	})); diff != "" {
+		t.Errorf("unexpected timings:\n%s", diff)
+	}
+	if d := doc.Time; d < 99.99 || 100.01 < d {
+		t.Errorf("unexpected total time: %f", d)
+	}
+
+	failed := har.Log.Entries[2]
+	if failed.Error != "net::ERR_CONNECTION_REFUSED" || failed.Response.Status != 0 {
+		t.Errorf("unexpected failed entry: %q %d", failed.Error, failed.Response.Status)
+	}
+	if !failed.StartedDateTime.Equal(time.Time(wall)) {
+		t.Errorf("unexpected start time: %s", failed.StartedDateTime)
+	}
+}
@@ -110,6 +110,13 @@ func (l *Logger) AsRecord(timestamp time.Time, latency time.Duration) ayd.Record
 	return r
 }
 
+func (l *Logger) GetStatus() ayd.Status {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.Status
+}
+
 func (l *Logger) SetStatus(status string) {
 	l.Lock()
 	defer l.Unlock()
@@ -164,6 +171,21 @@ func (l *Logger) SetExtra(k string, v any) {
 	}
 }
 
+// checkExtraKey reports an error if the key is reserved by Ayd.
+func checkExtraKey(key string) error {
+	switch strings.ToLower(key) {
+	case "message":
+		return errors.New("can not set message. please use print().")
+	case "status":
+		return errors.New("can not set status. please use print.status().")
+	case "latency":
+		return errors.New("can not set latency. please use print.latency().")
+	case "time", "target":
+		return fmt.Errorf("can not set %s.", key)
+	}
+	return nil
+}
+
 func RegisterLogger(L *lua.LState, logger *Logger) {
 	tbl := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
 		"status": func(L *lua.LState) int {
@@ -180,19 +202,11 @@ func RegisterLogger(L *lua.LState, logger *Logger) {
 		},
 		"extra": func(L *lua.LState) int {
 			key := L.CheckString(1)
-			switch strings.ToLower(key) {
-			case "message":
-				L.RaiseError("print.extra() can not set message. please use print().")
-			case "status":
-				L.RaiseError("print.extra() can not set status. please use print.status().")
-			case "latency":
-				L.RaiseError("print.extra() can not set latency. please use print.latency().")
-			case "time", "target":
-				L.RaiseError("print.extra() can not set %s.", key)
-			default:
-				value := UnpackLValue(L.CheckAny(2))
-				logger.SetExtra(key, value)
+			if err := checkExtraKey(key); err != nil {
+				L.RaiseError("print.extra() %s", err)
 			}
+			value := UnpackLValue(L.CheckAny(2))
+			logger.SetExtra(key, value)
 			return 0
 		},
 	})
@@ -0,0 +1,130 @@
+package webscenario
+
+import (
+	"context"
+
+	"github.com/chromedp/cdproto/performance"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// pageMetricsScript collects Navigation Timing, Paint Timing, and Web Vitals in the page.
+// All values are in milliseconds from the navigation start, except for CLS.
+const pageMetricsScript = `(async () => {
+	const result = {};
+
+	const nav = performance.getEntriesByType('navigation')[0];
+	if (nav) {
+		result.ttfb = nav.responseStart - (nav.activationStart || 0);
+		result.domContentLoaded = nav.domContentLoadedEventEnd;
+		result.load = nav.loadEventEnd;
+	}
+
+	for (const p of performance.getEntriesByType('paint')) {
+		if (p.name === 'first-paint') result.firstPaint = p.startTime;
+		if (p.name === 'first-contentful-paint') result.firstContentfulPaint = p.startTime;
+	}
+
+	const observe = (type, opts) => new Promise((resolve) => {
+		if (!PerformanceObserver.supportedEntryTypes.includes(type)) {
+			resolve(null);
+			return;
+		}
+		const entries = [];
+		const po = new PerformanceObserver((list) => entries.push(...list.getEntries()));
+		po.observe({type, buffered: true, ...opts});
+		setTimeout(() => {
+			entries.push(...po.takeRecords());
+			po.disconnect();
+			resolve(entries);
+		}, 0);
+	});
+
+	const [lcp, shifts, events] = await Promise.all([
+		observe('largest-contentful-paint'),
+		observe('layout-shift'),
+		observe('event', {durationThreshold: 16}),
+	]);
+
+	if (lcp && lcp.length > 0) {
+		result.lcp = lcp[lcp.length - 1].startTime;
+	}
+
+	if (shifts) {
+		let cls = 0, session = 0, first = 0, last = 0;
+		for (const s of shifts) {
+			if (s.hadRecentInput) continue;
+			if (session > 0 && s.startTime - last < 1000 && s.startTime - first < 5000) {
+				session += s.value;
+			} else {
+				session = s.value;
+				first = s.startTime;
+			}
+			last = s.startTime;
+			cls = Math.max(cls, session);
+		}
+		result.cls = cls;
+	}
+
+	if (events) {
+		const interactions = new Map();
+		for (const e of events) {
+			if (e.interactionId) {
+				interactions.set(e.interactionId, Math.max(interactions.get(e.interactionId) || 0, e.duration));
+			}
+		}
+		const ds = [...interactions.values()].sort((a, b) => b - a);
+		if (ds.length > 0) {
+			result.inp = ds[Math.min(ds.length - 1, Math.floor(ds.length / 50))];
+		}
+	}
+
+	return result;
+})()`
+
+// collectMetrics collects performance metrics of the current page.
+// The result has "cdp" key that includes counters from Performance.getMetrics of Chrome DevTools Protocol.
+func (t *Tab) collectMetrics(ctx context.Context) (map[string]any, error) {
+	m := make(map[string]any)
+	err := chromedp.Evaluate(pageMetricsScript, &m, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+		return p.WithAwaitPromise(true)
+	}).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The metrics can be collected by tab:metrics() and Tab.Close at the same time, and both run without the GIL.
+	t.performanceMu.Lock()
+	if !t.performanceEnabled {
+		if err := performance.Enable().Do(ctx); err != nil {
+			t.performanceMu.Unlock()
+			return nil, err
+		}
+		t.performanceEnabled = true
+	}
+	t.performanceMu.Unlock()
+
+	ms, err := performance.GetMetrics().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counters := make(map[string]any, len(ms))
+	for _, x := range ms {
+		counters[x.Name] = x.Value
+	}
+	m["cdp"] = counters
+
+	return m, nil
+}
+
+// publishMetrics reports metrics as an extra value of Ayd.
+// The counters from Chrome DevTools Protocol are omitted, because it is too noisy for monitoring.
+func (t *Tab) publishMetrics(key string, m map[string]any) {
+	x := make(map[string]any, len(m))
+	for k, v := range m {
+		if k != "cdp" {
+			x[k] = v
+		}
+	}
+	t.env.logger.SetExtra(key, x)
+}
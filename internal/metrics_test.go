@@ -0,0 +1,28 @@
+package webscenario
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTab_publishMetrics(t *testing.T) {
+	logger := &Logger{}
+	tab := &Tab{env: &Environment{logger: logger}}
+
+	tab.publishMetrics("home", map[string]any{
+		"ttfb": 12.5,
+		"cls":  0.0,
+		"cdp":  map[string]any{"Documents": 1.0},
+	})
+
+	want := map[string]any{
+		"home": map[string]any{
+			"ttfb": 12.5,
+			"cls":  0.0,
+		},
+	}
+	if diff := cmp.Diff(want, logger.Extra); diff != "" {
+		t.Errorf("unexpected extra:\n%s", diff)
+	}
+}
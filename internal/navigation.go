@@ -0,0 +1,366 @@
+package webscenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// WaitUntil is the condition to treat a navigation as finished.
+type WaitUntil int
+
+const (
+	// WaitCommit means the browser received the response and started to load the new document.
+	WaitCommit WaitUntil = iota
+
+	// WaitDOMContentLoaded means the DOMContentLoaded event fired.
+	WaitDOMContentLoaded
+
+	// WaitLoad means the load event fired.
+	WaitLoad
+
+	// WaitNetworkIdle means the load event fired and there is no network request for NetworkIdleTime.
+	WaitNetworkIdle
+)
+
+var waitUntilNames = map[string]WaitUntil{
+	"commit":           WaitCommit,
+	"domcontentloaded": WaitDOMContentLoaded,
+	"load":             WaitLoad,
+	"networkidle":      WaitNetworkIdle,
+}
+
+func ParseWaitUntil(s string) (WaitUntil, error) {
+	if w, ok := waitUntilNames[s]; ok {
+		return w, nil
+	}
+	return 0, fmt.Errorf("unknown waitUntil: %q", s)
+}
+
+// navigation watches events of the main frame to find the response of the new document, and to know when the navigation reaches the condition.
+type navigation struct {
+	sync.Mutex
+
+	until     WaitUntil
+	frameID   cdp.FrameID
+	loaderID  cdp.LoaderID
+	requestID network.RequestID
+	response  *NavigationResponse
+	redirects []*network.Response
+	err       error
+	done      chan struct{}
+}
+
+func newNavigation(frameID cdp.FrameID, until WaitUntil) *navigation {
+	return &navigation{
+		until:   until,
+		frameID: frameID,
+		done:    make(chan struct{}),
+	}
+}
+
+func (n *navigation) finish(err error) {
+	select {
+	case <-n.done:
+	default:
+		n.err = err
+		close(n.done)
+	}
+}
+
+// reach records the navigation reached the stage.
+// The network idle is not detected by events, so it finishes at the load event.
+func (n *navigation) reach(stage WaitUntil) {
+	if stage >= n.until || stage == WaitLoad {
+		n.finish(nil)
+	}
+}
+
+// matchLoader checks if the event is for the navigation.
+// The first loader of the main frame is treated as the navigation's one, because reload and history navigation don't tell the loader ID.
+func (n *navigation) matchLoader(id cdp.LoaderID) bool {
+	if n.loaderID == "" {
+		n.loaderID = id
+	}
+	return n.loaderID == id
+}
+
+// SetLoader sets the loader ID that is known by the result of the navigation command.
+// An empty ID means a navigation in the same document, so it finishes immediately.
+func (n *navigation) SetLoader(id cdp.LoaderID) {
+	n.Lock()
+	defer n.Unlock()
+
+	if id == "" {
+		n.finish(nil)
+	} else if n.loaderID == "" {
+		n.loaderID = id
+	}
+}
+
+func (n *navigation) Handle(ev any) {
+	n.Lock()
+	defer n.Unlock()
+
+	switch e := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		if e.FrameID == n.frameID && e.Type == network.ResourceTypeDocument && n.matchLoader(e.LoaderID) {
+			n.requestID = e.RequestID
+			if e.RedirectResponse != nil {
+				n.redirects = append(n.redirects, e.RedirectResponse)
+			}
+		}
+	case *network.EventResponseReceived:
+		if n.requestID != "" && e.RequestID == n.requestID {
+			n.response = &NavigationResponse{
+				Response:  e.Response,
+				Redirects: n.redirects,
+			}
+		}
+	case *network.EventLoadingFailed:
+		if n.requestID != "" && e.RequestID == n.requestID {
+			n.finish(fmt.Errorf("page load error %s", e.ErrorText))
+		}
+	case *page.EventFrameNavigated:
+		if e.Frame.ID == n.frameID && n.matchLoader(e.Frame.LoaderID) {
+			if e.Type == page.NavigationTypeBackForwardCacheRestore {
+				// The page restored from the cache doesn't load again.
+				n.finish(nil)
+			}
+			n.reach(WaitCommit)
+		}
+	case *page.EventLifecycleEvent:
+		if e.FrameID != n.frameID {
+			break
+		}
+		switch e.Name {
+		case "init":
+			n.matchLoader(e.LoaderID)
+		case "DOMContentLoaded":
+			if n.loaderID == e.LoaderID {
+				n.reach(WaitDOMContentLoaded)
+			}
+		case "load":
+			if n.loaderID == e.LoaderID {
+				n.reach(WaitLoad)
+			}
+		}
+	case *page.EventNavigatedWithinDocument:
+		if e.FrameID == n.frameID && n.requestID == "" {
+			n.finish(nil)
+		}
+	}
+}
+
+// Response returns the response of the main document, or nil if the navigation had no response.
+func (n *navigation) Response() *NavigationResponse {
+	n.Lock()
+	defer n.Unlock()
+	return n.response
+}
+
+// NavigationResponse is the response of the main document, and the redirect responses before it.
+type NavigationResponse struct {
+	Response  *network.Response
+	Redirects []*network.Response
+}
+
+// Check returns an HTTPError if the status of the response is 400 or greater.
+func (r *NavigationResponse) Check() error {
+	if r == nil || r.Response.Status < 400 {
+		return nil
+	}
+	return HTTPError{
+		Status:     r.Response.Status,
+		StatusText: r.Response.StatusText,
+		URL:        r.Response.URL,
+		Redirects:  r.Redirects,
+	}
+}
+
+// HTTPError is an error status of the main document.
+type HTTPError struct {
+	Status     int64
+	StatusText string
+	URL        string
+	Redirects  []*network.Response
+}
+
+func (e HTTPError) Error() string {
+	text := e.StatusText
+	if text == "" {
+		text = http.StatusText(int(e.Status))
+	}
+
+	msg := fmt.Sprintf("http error: %d %s: %s", e.Status, text, e.URL)
+	if len(e.Redirects) > 0 {
+		chain := make([]string, len(e.Redirects))
+		for i, r := range e.Redirects {
+			chain[i] = fmt.Sprintf("%s (%d)", r.URL, r.Status)
+		}
+		msg += " (redirected from " + strings.Join(chain, " -> ") + ")"
+	}
+	return msg
+}
+
+// Navigate makes an action that starts a navigation by the start action, and waits until the navigation reaches the condition.
+// The response of the main document is stored to res. It is nil if the navigation has no response like moving to a fragment.
+// It fails with HTTPError after reaching the condition if the tab has failOnHTTPError option.
+func (t *Tab) Navigate(start func(*navigation) chromedp.Action, until WaitUntil, res **NavigationResponse) chromedp.Action {
+	return t.withNetwork(chromedp.ActionFunc(func(ctx context.Context) error {
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return err
+		}
+		nav := newNavigation(tree.Frame.ID, until)
+
+		lctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		chromedp.ListenTarget(lctx, nav.Handle)
+
+		if err := start(nav).Do(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-nav.done:
+		}
+		if nav.err != nil {
+			return nav.err
+		}
+
+		if until == WaitNetworkIdle {
+			if err := t.network.WaitIdle(ctx, NetworkIdleTime); err != nil {
+				return err
+			}
+		}
+
+		resp := nav.Response()
+		if res != nil {
+			*res = resp
+		}
+		if t.failOnHTTPError {
+			return resp.Check()
+		}
+		return nil
+	}))
+}
+
+// navigateURL makes a start action for Navigate to open the URL.
+func navigateURL(url, referer string) func(*navigation) chromedp.Action {
+	return func(nav *navigation) chromedp.Action {
+		return chromedp.ActionFunc(func(ctx context.Context) error {
+			_, loaderID, errorText, err := page.Navigate(url).WithReferrer(referer).Do(ctx)
+			if err != nil {
+				return err
+			}
+			if errorText != "" {
+				return fmt.Errorf("page load error %s", errorText)
+			}
+			nav.SetLoader(loaderID)
+			return nil
+		})
+	}
+}
+
+// navigateHistory makes a start action for Navigate to move in the history by delta.
+func navigateHistory(delta int64) func(*navigation) chromedp.Action {
+	return func(nav *navigation) chromedp.Action {
+		return chromedp.ActionFunc(func(ctx context.Context) error {
+			cur, entries, err := page.GetNavigationHistory().Do(ctx)
+			if err != nil {
+				return err
+			}
+			if cur+delta < 0 || cur+delta >= int64(len(entries)) {
+				return errors.New("invalid navigation entry")
+			}
+			return page.NavigateToHistoryEntry(entries[cur+delta].ID).Do(ctx)
+		})
+	}
+}
+
+// reloadPage makes a start action for Navigate to reload the page.
+func reloadPage(nav *navigation) chromedp.Action {
+	return page.Reload()
+}
+
+// PackNavigationResponse makes a Lua table of the response of a navigation.
+func PackNavigationResponse(L *lua.LState, r *NavigationResponse) lua.LValue {
+	if r == nil {
+		return lua.LNil
+	}
+
+	tbl := L.NewTable()
+	L.SetField(tbl, "url", lua.LString(r.Response.URL))
+	L.SetField(tbl, "status", lua.LNumber(r.Response.Status))
+	L.SetField(tbl, "statusText", lua.LString(r.Response.StatusText))
+	L.SetField(tbl, "headers", PackLValue(L, r.Response.Headers))
+	L.SetField(tbl, "mimeType", lua.LString(r.Response.MimeType))
+	L.SetField(tbl, "remoteIP", lua.LString(r.Response.RemoteIPAddress))
+	L.SetField(tbl, "remotePort", lua.LNumber(r.Response.RemotePort))
+
+	redirects := L.NewTable()
+	for _, x := range r.Redirects {
+		rt := L.NewTable()
+		L.SetField(rt, "url", lua.LString(x.URL))
+		L.SetField(rt, "status", lua.LNumber(x.Status))
+		redirects.Append(rt)
+	}
+	L.SetField(tbl, "redirects", redirects)
+
+	return tbl
+}
+
+// checkGoOptions parses options for tab:go() at the n-th argument.
+// The default timeout is no limit, because loading a page can take long time.
+func checkGoOptions(L *lua.LState, n int) (until WaitUntil, timeout time.Duration, referer string) {
+	until = WaitLoad
+
+	opts := L.OptTable(n, nil)
+	if opts == nil {
+		return
+	}
+
+	switch v := L.GetField(opts, "waitUntil").(type) {
+	case *lua.LNilType:
+	case lua.LString:
+		var err error
+		if until, err = ParseWaitUntil(string(v)); err != nil {
+			L.ArgError(n, err.Error()+`. it should be "commit", "domcontentloaded", "load", or "networkidle".`)
+		}
+	default:
+		L.ArgError(n, "waitUntil field expected string value.")
+	}
+
+	switch v := L.GetField(opts, "timeout").(type) {
+	case *lua.LNilType:
+	case lua.LNumber:
+		if v > 0 {
+			timeout = time.Duration(float64(v) * float64(time.Millisecond))
+		}
+	default:
+		L.ArgError(n, "timeout field expected number value.")
+	}
+
+	switch v := L.GetField(opts, "referer").(type) {
+	case *lua.LNilType:
+	case lua.LString:
+		referer = string(v)
+	default:
+		L.ArgError(n, "referer field expected string value.")
+	}
+
+	return
+}
@@ -0,0 +1,161 @@
+package webscenario
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+)
+
+func TestParseWaitUntil(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Input  string
+		Output WaitUntil
+		Error  bool
+	}{
+		{"commit", WaitCommit, false},
+		{"domcontentloaded", WaitDOMContentLoaded, false},
+		{"load", WaitLoad, false},
+		{"networkidle", WaitNetworkIdle, false},
+		{"Load", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		w, err := ParseWaitUntil(tt.Input)
+		if (err != nil) != tt.Error {
+			t.Errorf("%q: unexpected error: %v", tt.Input, err)
+		} else if w != tt.Output {
+			t.Errorf("%q: expected %d but got %d", tt.Input, tt.Output, w)
+		}
+	}
+}
+
+func isDone(nav *navigation) bool {
+	select {
+	case <-nav.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func Test_navigation(t *testing.T) {
+	t.Parallel()
+
+	events := []any{
+		&network.EventRequestWillBeSent{RequestID: "old", LoaderID: "other", FrameID: "sub", Type: network.ResourceTypeDocument},
+		&network.EventRequestWillBeSent{RequestID: "req", LoaderID: "loader", FrameID: "main", Type: network.ResourceTypeDocument},
+		&network.EventResponseReceived{RequestID: "old", Response: &network.Response{Status: 404}},
+		&network.EventRequestWillBeSent{RequestID: "req", LoaderID: "loader", FrameID: "main", Type: network.ResourceTypeDocument, RedirectResponse: &network.Response{URL: "http://example.com/a", Status: 302}},
+		&network.EventResponseReceived{RequestID: "req", Response: &network.Response{Status: 200}},
+		&page.EventFrameNavigated{Frame: &cdp.Frame{ID: "main", LoaderID: "loader"}},
+		&page.EventLifecycleEvent{FrameID: "main", LoaderID: "other", Name: "DOMContentLoaded"},
+		&page.EventLifecycleEvent{FrameID: "main", LoaderID: "loader", Name: "DOMContentLoaded"},
+		&page.EventLifecycleEvent{FrameID: "main", LoaderID: "loader", Name: "load"},
+	}
+	finishAt := map[WaitUntil]int{
+		WaitCommit:           5,
+		WaitDOMContentLoaded: 7,
+		WaitLoad:             8,
+		WaitNetworkIdle:      8,
+	}
+
+	for until, at := range finishAt {
+		nav := newNavigation("main", until)
+		for i, ev := range events {
+			if isDone(nav) {
+				t.Fatalf("%d: finished before event #%d", until, i)
+			}
+			nav.Handle(ev)
+			if i == at {
+				break
+			}
+		}
+		if !isDone(nav) {
+			t.Fatalf("%d: not finished after event #%d", until, at)
+		}
+		if nav.err != nil {
+			t.Fatalf("%d: unexpected error: %s", until, nav.err)
+		}
+		if r := nav.Response(); r == nil || r.Response.Status != 200 || len(r.Redirects) != 1 || r.Redirects[0].Status != 302 {
+			t.Fatalf("%d: unexpected response: %v", until, r)
+		}
+	}
+}
+
+func Test_navigation_failed(t *testing.T) {
+	t.Parallel()
+
+	nav := newNavigation("main", WaitLoad)
+	nav.Handle(&network.EventRequestWillBeSent{RequestID: "req", LoaderID: "loader", FrameID: "main", Type: network.ResourceTypeDocument})
+	nav.Handle(&network.EventLoadingFailed{RequestID: "req", ErrorText: "net::ERR_CONNECTION_REFUSED"})
+
+	if !isDone(nav) {
+		t.Fatalf("expected finished")
+	}
+	if nav.err == nil || nav.err.Error() != "page load error net::ERR_CONNECTION_REFUSED" {
+		t.Fatalf("unexpected error: %v", nav.err)
+	}
+}
+
+func Test_navigation_sameDocument(t *testing.T) {
+	t.Parallel()
+
+	nav := newNavigation("main", WaitLoad)
+	nav.SetLoader("")
+	if !isDone(nav) || nav.Response() != nil {
+		t.Fatalf("expected finished without response")
+	}
+
+	nav = newNavigation("main", WaitLoad)
+	nav.Handle(&page.EventNavigatedWithinDocument{FrameID: "main"})
+	if !isDone(nav) || nav.Response() != nil {
+		t.Fatalf("expected finished without response")
+	}
+}
+
+func TestNavigationResponse_Check(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Response *NavigationResponse
+		Error    string
+	}{
+		{nil, ""},
+		{&NavigationResponse{Response: &network.Response{URL: "http://example.com/", Status: 200}}, ""},
+		{&NavigationResponse{Response: &network.Response{URL: "http://example.com/", Status: 399}}, ""},
+		{
+			&NavigationResponse{Response: &network.Response{URL: "http://example.com/", Status: 404, StatusText: "Not Found"}},
+			"http error: 404 Not Found: http://example.com/",
+		},
+		{
+			&NavigationResponse{Response: &network.Response{URL: "http://example.com/", Status: 503}},
+			"http error: 503 Service Unavailable: http://example.com/",
+		},
+		{
+			&NavigationResponse{
+				Response: &network.Response{URL: "http://example.com/c", Status: 500, StatusText: "Internal Server Error"},
+				Redirects: []*network.Response{
+					{URL: "http://example.com/a", Status: 301},
+					{URL: "http://example.com/b", Status: 302},
+				},
+			},
+			"http error: 500 Internal Server Error: http://example.com/c (redirected from http://example.com/a (301) -> http://example.com/b (302))",
+		},
+	}
+
+	for _, tt := range tests {
+		err := tt.Response.Check()
+		if tt.Error == "" {
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		} else if err == nil || err.Error() != tt.Error {
+			t.Errorf("expected %q but got %v", tt.Error, err)
+		}
+	}
+}
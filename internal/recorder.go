@@ -6,15 +6,18 @@ import (
 	"errors"
 	"image"
 	"image/color"
-	"image/color/palette"
-	"image/gif"
 	"image/png"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/chromedp"
 	"golang.org/x/image/draw"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/goregular"
@@ -31,31 +34,125 @@ var (
 	NoRecord = errors.New("no record")
 )
 
+// PaletteSize is the number of colors in a frame, excluding the transparent color.
+const PaletteSize = 255
+
 var (
-	Palette = append(palette.WebSafe, color.Transparent)
+	HighlightColor = color.RGBA{255, 48, 48, 255}
 )
 
 type recorderTask struct {
-	Where      string
-	Screenshot *[]byte
+	Where         string
+	Screenshot    *[]byte
+	Width, Height int
+	Time          time.Time
+	Target        *RecordTarget
+}
+
+// RecordTarget is the element that an action worked on, to highlight it in the recording.
+// The coordinates are CSS pixels in the viewport.
+type RecordTarget struct {
+	Box   image.Rectangle
+	Point *image.Point
+}
+
+// Locate makes an action to find the position of the node.
+// It scrolls the node into view like click does, so that the position is the same as when the action is performed.
+// The target keeps empty if the node has no box, and the action doesn't fail in that case.
+func (t *RecordTarget) Locate(node *cdp.Node, click bool) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		dom.ScrollIntoViewIfNeeded().WithNodeID(node.NodeID).Do(ctx)
+
+		box, err := dom.GetBoxModel().WithNodeID(node.NodeID).Do(ctx)
+		if err != nil {
+			return nil
+		}
+		t.Box = quadBounds(box.Border)
+		if click {
+			c := quadBounds(box.Content)
+			t.Point = &image.Point{(c.Min.X + c.Max.X) / 2, (c.Min.Y + c.Max.Y) / 2}
+		}
+		return nil
+	})
+}
+
+func quadBounds(q dom.Quad) image.Rectangle {
+	if len(q) < 8 {
+		return image.Rectangle{}
+	}
+	minX, minY, maxX, maxY := q[0], q[1], q[0], q[1]
+	for i := 2; i+1 < len(q); i += 2 {
+		minX, maxX = math.Min(minX, q[i]), math.Max(maxX, q[i])
+		minY, maxY = math.Min(minY, q[i+1]), math.Max(maxY, q[i+1])
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
+
+// Translate moves the target by the offset.
+func (t *RecordTarget) Translate(offset image.Point) {
+	t.Box = t.Box.Add(offset)
+	if t.Point != nil {
+		p := t.Point.Add(offset)
+		t.Point = &p
+	}
+}
+
+// Draw draws a box around the target, and a marker at the clicked point.
+func (t *RecordTarget) Draw(img draw.Image, clip image.Rectangle) {
+	const border = 3
+	uniform := &image.Uniform{HighlightColor}
+
+	if !t.Box.Empty() {
+		b := t.Box.Inset(-border)
+		for _, r := range []image.Rectangle{
+			image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+border),
+			image.Rect(b.Min.X, b.Max.Y-border, b.Max.X, b.Max.Y),
+			image.Rect(b.Min.X, b.Min.Y, b.Min.X+border, b.Max.Y),
+			image.Rect(b.Max.X-border, b.Min.Y, b.Max.X, b.Max.Y),
+		} {
+			draw.Draw(img, r.Intersect(clip), uniform, image.ZP, draw.Over)
+		}
+	}
+
+	if t.Point != nil {
+		const outer, inner = 10, 6
+		p := *t.Point
+		for y := -outer; y <= outer; y++ {
+			for x := -outer; x <= outer; x++ {
+				d := x*x + y*y
+				q := image.Point{p.X + x, p.Y + y}
+				if q.In(clip) && (d <= outer*outer && d >= inner*inner || d <= 4) {
+					img.Set(q.X, q.Y, HighlightColor)
+				}
+			}
+		}
+	}
 }
 
+// Recorder records a frame of GIF for each action.
+// The frames are written into a temporary file while recording, and saved by SaveTo after the Done channel is closed.
 type Recorder struct {
-	images []*image.Paletted
+	sync.Mutex
+
+	stream *gifStream
 	ch     chan<- recorderTask
 	stop   context.CancelFunc
 
 	width, height int
+	err           error
 
 	Done chan struct{}
 }
 
-func NewRecorder(ctx context.Context, width, height int) *Recorder {
+// NewRecorder makes a new Recorder.
+// The maxFrames and maxBytes limit the size of the GIF. Frames after reaching the limit are dropped. Zero means unlimited.
+func NewRecorder(ctx context.Context, width, height, maxFrames int, maxBytes int64) *Recorder {
 	ch := make(chan recorderTask, 8)
 
 	ctx, cancel := context.WithCancel(ctx)
 
 	rec := &Recorder{
+		stream: &gifStream{MaxFrames: maxFrames, MaxBytes: maxBytes},
 		ch:     ch,
 		stop:   cancel,
 		width:  width,
@@ -63,7 +160,7 @@ func NewRecorder(ctx context.Context, width, height int) *Recorder {
 		Done:   make(chan struct{}),
 	}
 
-	go rec.runRecorder(ch, width, height)
+	go rec.runRecorder(ch)
 	go func() {
 		<-ctx.Done()
 		close(ch)
@@ -85,79 +182,106 @@ func parseWhere(where string) (string, int) {
 	return where[:pos], line
 }
 
-func (r *Recorder) runRecorder(ch <-chan recorderTask, width, height int) {
-	screenSize := image.Rect(0, 0, width, height)
-	recordSize := image.Rect(0, 0, width+SourceWidth, height)
+// Resize changes the size of the screen area for the frames that will be recorded after this.
+func (r *Recorder) Resize(width, height int) {
+	r.Lock()
+	defer r.Unlock()
+	r.width = width
+	r.height = height
+}
 
+func (r *Recorder) size() (width, height int) {
+	r.Lock()
+	defer r.Unlock()
+	return r.width, r.height
+}
+
+func (r *Recorder) runRecorder(ch <-chan recorderTask) {
 	for task := range ch {
+		width, height := task.Width, task.Height
+		screenSize := image.Rect(0, 0, width, height)
+		recordSize := image.Rect(0, 0, width+SourceWidth, height)
+
 		orig, err := png.Decode(bytes.NewReader(*task.Screenshot))
 		if err != nil {
 			// TODO: add error handling
 			continue
 		}
 
-		img := image.NewPaletted(recordSize, Palette)
-		draw.FloydSteinberg.Draw(img, screenSize, orig, image.ZP)
+		if b := orig.Bounds(); b.Dx() != width && b.Dx() > 0 {
+			// The screenshot is larger than the viewport if the device scale factor is not 1.
+			scaled := image.NewRGBA(image.Rect(0, 0, width, b.Dy()*width/b.Dx()))
+			draw.ApproxBiLinear.Scale(scaled, scaled.Rect, orig, b, draw.Src, nil)
+			orig = scaled
+		}
+
+		full := image.NewRGBA(recordSize)
+		draw.Draw(full, recordSize, image.Black, image.ZP, draw.Src)
+		draw.Draw(full, screenSize, orig, image.ZP, draw.Src)
+
+		if task.Target != nil {
+			task.Target.Draw(full, screenSize)
+		}
 
 		where, line := parseWhere(task.Where)
-		sourceImager.LoadAsImage(img, image.Rect(width, 0, recordSize.Max.X, height), where, line)
+		sourceImager.LoadAsImage(full, image.Rect(width, 0, recordSize.Max.X, height), where, line)
 
-		r.images = append(r.images, img)
+		img := image.NewPaletted(recordSize, append(medianCut(full, PaletteSize), color.Transparent))
+		draw.FloydSteinberg.Draw(img, recordSize, full, image.ZP)
+
+		r.setError(r.stream.Add(img, task.Time))
 	}
+	r.setError(r.stream.Flush())
 	close(r.Done)
 }
 
+// setError keeps the first error in writing frames, to report it by SaveTo.
+func (r *Recorder) setError(err error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
 type RecordAction struct {
-	ch   chan<- recorderTask
+	rec  *Recorder
 	task recorderTask
 }
 
 func (a RecordAction) Do(ctx context.Context) error {
-	a.ch <- a.task
+	a.task.Width, a.task.Height = a.rec.size()
+	a.task.Time = time.Now()
+	a.rec.ch <- a.task
 	return nil
 }
 
-func (r *Recorder) Record(where string, screenshot *[]byte) RecordAction {
+// Record makes an action to record a frame.
+// The target is highlighted in the frame if it is not nil.
+func (r *Recorder) Record(where string, screenshot *[]byte, target *RecordTarget) RecordAction {
 	return RecordAction{
-		ch: r.ch,
+		rec: r,
 		task: recorderTask{
 			Where:      where,
 			Screenshot: screenshot,
+			Target:     target,
 		},
 	}
 }
 
-func compressGif(images []*image.Paletted) {
-	width := images[0].Rect.Max.X
-	height := images[0].Rect.Max.Y
-
-	for i := len(images) - 1; i > 0; i-- {
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				if images[i].ColorIndexAt(x, y) == images[i-1].ColorIndexAt(x, y) {
-					images[i].SetColorIndex(x, y, uint8(len(Palette)-1))
-				}
-			}
-		}
-	}
-}
-
+// SaveTo writes the recorded GIF, and removes the temporary file.
+// It returns the error if writing a frame has failed while recording.
 func (r *Recorder) SaveTo(f io.Writer) error {
-	if len(r.images) == 0 {
-		return NoRecord
-	}
+	defer r.stream.Close()
 
-	compressGif(r.images)
-
-	g := gif.GIF{
-		Image: r.images,
-	}
-	for range g.Image {
-		g.Delay = append(g.Delay, 200)
+	r.Lock()
+	err := r.err
+	r.Unlock()
+	if err != nil {
+		return err
 	}
-	g.Delay[len(g.Delay)-1] = 400
 
-	return gif.EncodeAll(f, &g)
+	return r.stream.SaveTo(f)
 }
 
 type SourceImager struct {
@@ -223,7 +347,7 @@ func (s *SourceImager) RecordStdin(lines []string) {
 	}
 }
 
-func (s *SourceImager) LoadAsImage(img *image.Paletted, rect image.Rectangle, path string, line int) {
+func (s *SourceImager) LoadAsImage(img draw.Image, rect image.Rectangle, path string, line int) {
 	lines, err := s.Load(path)
 	if err != nil {
 		return
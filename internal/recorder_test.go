@@ -2,12 +2,19 @@ package webscenario
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"testing"
+	"time"
 
 	"image"
+	"image/color"
 	"image/gif"
+
+	"github.com/chromedp/cdproto/dom"
+	"github.com/google/go-cmp/cmp"
 )
 
 func LoadGif(t testing.TB, name string) *image.Paletted {
@@ -83,3 +90,201 @@ func Test_compressGif(t *testing.T) {
 		}
 	}
 }
+
+func Test_gifStream(t *testing.T) {
+	t.Parallel()
+
+	p := color.Palette{color.Black, color.White, color.Transparent}
+	small := image.NewPaletted(image.Rect(0, 0, 10, 20), p)
+	large := image.NewPaletted(image.Rect(0, 0, 30, 15), p)
+	large.SetColorIndex(0, 0, 1)
+	same := image.NewPaletted(image.Rect(0, 0, 30, 15), p)
+	same.SetColorIndex(1, 0, 1)
+
+	start := time.Now()
+	s := &gifStream{}
+	defer s.Close()
+	s.Add(small, start)
+	s.Add(large, start.Add(500*time.Millisecond))
+	s.Add(same, start.Add(800*time.Millisecond))
+	if err := s.Flush(); err != nil {
+		t.Fatalf("failed to flush: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.SaveTo(&buf); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if g.Config.Width != 30 || g.Config.Height != 20 {
+		t.Errorf("unexpected screen size: %dx%d", g.Config.Width, g.Config.Height)
+	}
+	if diff := cmp.Diff([]int{50, 30, lastGifDelay}, g.Delay); diff != "" {
+		t.Errorf("unexpected delays:\n%s", diff)
+	}
+	if g.Disposal[0] != gif.DisposalBackground {
+		t.Errorf("the frame before resizing should be disposed but got %d", g.Disposal[0])
+	}
+	if g.Image[1].ColorIndexAt(0, 0) != 1 {
+		t.Errorf("the frame after resizing should not be compressed")
+	}
+	if g.Image[2].ColorIndexAt(0, 0) != 0 || g.Image[2].ColorIndexAt(1, 0) != 1 || g.Image[2].ColorIndexAt(2, 0) != 2 {
+		t.Errorf("the frame after the same size frame should be compressed")
+	}
+}
+
+func Test_gifStream_limit(t *testing.T) {
+	t.Parallel()
+
+	p := color.Palette{color.Black, color.White, color.Transparent}
+	add := func(s *gifStream, n int) {
+		start := time.Now()
+		for i := 0; i < n; i++ {
+			img := image.NewPaletted(image.Rect(0, 0, 100, 100), p)
+			for j := 0; j < 100; j++ {
+				img.SetColorIndex((i*7+j*13)%100, j, uint8(i%2))
+			}
+			s.Add(img, start.Add(time.Duration(i)*time.Second))
+		}
+		s.Flush()
+	}
+
+	frames := &gifStream{MaxFrames: 3}
+	defer frames.Close()
+	add(frames, 10)
+	if frames.frames != 3 {
+		t.Errorf("expected 3 frames but got %d", frames.frames)
+	}
+
+	bytesLimited := &gifStream{MaxBytes: 1000}
+	defer bytesLimited.Close()
+	add(bytesLimited, 10)
+	var buf bytes.Buffer
+	if err := bytesLimited.SaveTo(&buf); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+	if buf.Len() > 1000 {
+		t.Errorf("the size should be less than 1000 bytes but got %d bytes", buf.Len())
+	}
+	if _, err := gif.DecodeAll(&buf); err != nil {
+		t.Errorf("failed to decode: %s", err)
+	}
+
+	tooSmall := &gifStream{MaxBytes: 10}
+	defer tooSmall.Close()
+	add(tooSmall, 1)
+	if err := tooSmall.SaveTo(io.Discard); err == nil || err == NoRecord {
+		t.Errorf("expected an error about the limit but got %v", err)
+	}
+}
+
+func TestRecorder_SaveTo_error(t *testing.T) {
+	t.Parallel()
+
+	first := errors.New("first error")
+
+	r := &Recorder{stream: &gifStream{}}
+	r.setError(nil)
+	r.setError(first)
+	r.setError(errors.New("second error"))
+
+	if err := r.SaveTo(io.Discard); err != first {
+		t.Errorf("expected the first error but got %v", err)
+	}
+}
+
+func Test_medianCut(t *testing.T) {
+	t.Parallel()
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			switch {
+			case x < 32:
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			case y < 32:
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			default:
+				img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 0, 255})
+			}
+		}
+	}
+
+	p := medianCut(img, 16)
+	if len(p) != 16 {
+		t.Fatalf("unexpected palette size: %d", len(p))
+	}
+	for _, c := range []color.Color{color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}} {
+		if p[p.Index(c)] != c {
+			t.Errorf("%v should be in the palette: %v", c, p)
+		}
+	}
+
+	if p := medianCut(image.NewRGBA(image.Rect(0, 0, 4, 4)), 16); len(p) != 1 {
+		t.Errorf("single color image should make single color palette: %v", p)
+	}
+}
+
+func Test_quadBounds(t *testing.T) {
+	r := quadBounds(dom.Quad{10.5, 20, 30, 20, 30, 40.2, 10.5, 40.2})
+	if r != image.Rect(10, 20, 30, 41) {
+		t.Errorf("unexpected bounds: %v", r)
+	}
+
+	if r := quadBounds(nil); !r.Empty() {
+		t.Errorf("empty quad should be empty rectangle: %v", r)
+	}
+}
+
+func TestRecordTarget_Draw(t *testing.T) {
+	t.Parallel()
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	target := RecordTarget{
+		Box:   image.Rect(20, 20, 60, 40),
+		Point: &image.Point{40, 30},
+	}
+	target.Draw(img, image.Rect(0, 0, 50, 100))
+
+	tests := []struct {
+		X, Y int
+		Want bool
+	}{
+		{18, 30, true},  // left border
+		{40, 18, true},  // top border
+		{30, 25, false}, // inside of the box
+		{40, 30, true},  // center of the marker
+		{48, 30, true},  // ring of the marker
+		{61, 30, false}, // right border is out of the clip
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.X, tt.Y) == HighlightColor; got != tt.Want {
+			t.Errorf("(%d, %d): expected highlighted=%v but got %v", tt.X, tt.Y, tt.Want, got)
+		}
+	}
+}
+
+func TestRecordTarget_Translate(t *testing.T) {
+	target := &RecordTarget{
+		Box:   image.Rect(20, 20, 60, 40),
+		Point: &image.Point{40, 30},
+	}
+	target.Translate(image.Point{100, 10})
+
+	if target.Box != image.Rect(120, 30, 160, 50) {
+		t.Errorf("unexpected box: %v", target.Box)
+	}
+	if *target.Point != (image.Point{140, 40}) {
+		t.Errorf("unexpected point: %v", *target.Point)
+	}
+
+	empty := &RecordTarget{}
+	empty.Translate(image.Point{100, 10})
+	if !empty.Box.Empty() || empty.Point != nil {
+		t.Errorf("empty target should keep empty: %v", empty)
+	}
+}
@@ -45,7 +45,7 @@ func TestEnvironment_DoStream(t *testing.T) {
 	}
 
 	var log strings.Builder
-	env := NewEnvironment(ctx, &Logger{Stream: &log}, storage, Arg{Mode: "stdin", Target: &ayd.URL{Scheme: "web-scenario", Opaque: "<stdin>"}, Timeout: 5 * time.Minute})
+	env := NewEnvironment(ctx, &Logger{Stream: &log}, storage, Arg{Mode: "stdin", Target: &ayd.URL{Scheme: "web-scenario", Opaque: "<stdin>"}, Timeout: 5 * time.Minute, DefaultTimeout: DefaultTimeout})
 	defer env.Close()
 
 	tests := []struct {
@@ -0,0 +1,160 @@
+package webscenario
+
+import (
+	"encoding/base64"
+	"sort"
+	"sync"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// matchURLPattern reports whether the url matches to the pattern.
+// The syntax of pattern is the same as Fetch.RequestPattern of Chrome DevTools Protocol; '*' matches zero or more characters, '?' matches exactly one character, and '\' escapes the next character.
+func matchURLPattern(pattern, url string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(url); i >= 0; i-- {
+				if matchURLPattern(pattern[1:], url[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(url) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(url) == 0 || url[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		url = url[1:]
+	}
+	return len(url) == 0
+}
+
+type route struct {
+	pattern string
+	handler *lua.LFunction
+}
+
+type Router struct {
+	sync.Mutex
+
+	routes []route
+}
+
+// Set registers a handler for the pattern.
+// If the handler is nil, the route for the pattern will be removed.
+func (r *Router) Set(pattern string, handler *lua.LFunction) {
+	r.Lock()
+	defer r.Unlock()
+
+	routes := make([]route, 0, len(r.routes)+1)
+	for _, x := range r.routes {
+		if x.pattern != pattern {
+			routes = append(routes, x)
+		}
+	}
+	if handler != nil {
+		routes = append(routes, route{pattern, handler})
+	}
+	r.routes = routes
+}
+
+// Match returns a handler for the url.
+// The route registered later has priority.
+func (r *Router) Match(url string) *lua.LFunction {
+	r.Lock()
+	defer r.Unlock()
+
+	for i := len(r.routes) - 1; i >= 0; i-- {
+		if matchURLPattern(r.routes[i].pattern, url) {
+			return r.routes[i].handler
+		}
+	}
+	return nil
+}
+
+func (r *Router) Patterns() []*fetch.RequestPattern {
+	r.Lock()
+	defer r.Unlock()
+
+	ps := make([]*fetch.RequestPattern, len(r.routes))
+	for i, x := range r.routes {
+		ps[i] = &fetch.RequestPattern{URLPattern: x.pattern}
+	}
+	return ps
+}
+
+func unpackRouteHeader(L *lua.LState, lv lua.LValue) ([]*fetch.HeaderEntry, error) {
+	h, err := UnpackFetchHeader(L, lv)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var es []*fetch.HeaderEntry
+	for _, k := range keys {
+		for _, v := range h[k] {
+			es = append(es, &fetch.HeaderEntry{Name: k, Value: v})
+		}
+	}
+	return es, nil
+}
+
+// buildRouteAction makes an action to handle paused request, from the return value of route handler.
+// If the return value is invalid, it returns an action to fail the request and the error.
+func buildRouteAction(L *lua.LState, id fetch.RequestID, ret lua.LValue) (chromedp.Action, error) {
+	switch r := ret.(type) {
+	case lua.LBool:
+		if r == lua.LFalse {
+			return fetch.FailRequest(id, network.ErrorReasonBlockedByClient), nil
+		}
+	case *lua.LTable:
+		headers, err := unpackRouteHeader(L, L.GetField(r, "headers"))
+		if err != nil {
+			return fetch.FailRequest(id, network.ErrorReasonFailed), err
+		}
+		body, hasBody := L.GetField(r, "body").(lua.LString)
+
+		if status, ok := L.GetField(r, "status").(lua.LNumber); ok {
+			action := fetch.FulfillRequest(id, int64(status)).WithResponseHeaders(headers)
+			if hasBody {
+				action = action.WithBody(base64.StdEncoding.EncodeToString([]byte(body)))
+			}
+			return action, nil
+		}
+
+		action := fetch.ContinueRequest(id)
+		if u, ok := L.GetField(r, "url").(lua.LString); ok {
+			action = action.WithURL(string(u))
+		}
+		if m, ok := L.GetField(r, "method").(lua.LString); ok {
+			action = action.WithMethod(string(m))
+		}
+		if len(headers) > 0 {
+			action = action.WithHeaders(headers)
+		}
+		if hasBody {
+			action = action.WithPostData(base64.StdEncoding.EncodeToString([]byte(body)))
+		}
+		return action, nil
+	}
+	return fetch.ContinueRequest(id), nil
+}
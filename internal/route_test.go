@@ -0,0 +1,56 @@
+package webscenario
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_matchURLPattern(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		URL     string
+		Want    bool
+	}{
+		{"*", "https://example.com/", true},
+		{"*", "", true},
+		{"https://example.com/", "https://example.com/", true},
+		{"https://example.com/", "https://example.com/foo", false},
+		{"https://example.com/*", "https://example.com/foo/bar", true},
+		{"*.png", "https://example.com/image.png", true},
+		{"*.png", "https://example.com/image.png?v=1", false},
+		{"*://*.example.com/*", "https://www.example.com/", true},
+		{"*://*.example.com/*", "https://example.com/", false},
+		{"https://example.com/?", "https://example.com/a", true},
+		{"https://example.com/?", "https://example.com/", false},
+		{"https://example.com/?", "https://example.com/ab", false},
+		{`*\*`, "https://example.com/*", true},
+		{`*\*`, "https://example.com/a", false},
+		{`*\?x=1`, "https://example.com/?x=1", true},
+		{`*\?x=1`, "https://example.com/ax=1", false},
+	}
+
+	for _, tt := range tests {
+		if actual := matchURLPattern(tt.Pattern, tt.URL); actual != tt.Want {
+			t.Errorf("%q %q: expected %v but got %v", tt.Pattern, tt.URL, tt.Want, actual)
+		}
+	}
+}
+
+func Test_buildRouteAction_invalidHeaders(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	ret := L.NewTable()
+	L.SetField(ret, "status", lua.LNumber(200))
+	L.SetField(ret, "headers", lua.LString("invalid"))
+
+	action, err := buildRouteAction(L, "1", ret)
+	if err == nil {
+		t.Fatalf("expected error but got nil")
+	}
+	if _, ok := action.(*fetch.FailRequestParams); !ok {
+		t.Errorf("expected fail request but got %T", action)
+	}
+}
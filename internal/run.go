@@ -7,15 +7,16 @@ import (
 	"os/signal"
 	"time"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/target"
 	"github.com/chromedp/chromedp"
 	"github.com/macrat/ayd/lib-ayd"
 )
 
-func NewExecAllocator(ctx context.Context, withHead bool) (context.Context, context.CancelFunc) {
+func NewExecAllocator(ctx context.Context, arg Arg) (context.Context, context.CancelFunc) {
 	opts := []chromedp.ExecAllocatorOption{
 		chromedp.NoFirstRun,
 		chromedp.NoDefaultBrowserCheck,
-		chromedp.WindowSize(800, 800),
 
 		chromedp.Flag("disable-background-networking", true),
 		chromedp.Flag("enable-features", "NetworkService,NetworkServiceInProcess"),
@@ -40,12 +41,78 @@ func NewExecAllocator(ctx context.Context, withHead bool) (context.Context, cont
 		chromedp.Flag("password-store", "basic"),
 		chromedp.Flag("use-mock-keychain", true),
 	}
-	if !withHead {
+	if !arg.Head {
 		opts = append(opts, chromedp.Headless)
 	}
+	if arg.UserDataDir != "" {
+		opts = append(opts, chromedp.UserDataDir(arg.UserDataDir))
+	}
+	opts = append(opts, arg.Browser.ExecAllocatorOptions()...)
 	return chromedp.NewExecAllocator(ctx, opts...)
 }
 
+// NewAllocator makes a browser allocator.
+// It connects to the browser at arg.BrowserURL if set, otherwise launches a new browser.
+func NewAllocator(ctx context.Context, arg Arg) (context.Context, context.CancelFunc) {
+	if arg.BrowserURL != "" {
+		return chromedp.NewRemoteAllocator(ctx, arg.BrowserURL)
+	}
+	return NewExecAllocator(ctx, arg)
+}
+
+// browserLogOptions makes options to write logs of the browser connection into debuglog.
+func browserLogOptions(debuglog *ayd.Logger) []chromedp.BrowserOption {
+	if debuglog == nil {
+		return nil
+	}
+	return []chromedp.BrowserOption{
+		chromedp.WithBrowserLogf(func(s string, args ...any) {
+			debuglog.Healthy(fmt.Sprintf(s, args), map[string]any{
+				"level": "log",
+			})
+		}),
+		chromedp.WithBrowserDebugf(func(s string, args ...any) {
+			debuglog.Healthy(fmt.Sprintf(s, args), map[string]any{
+				"level": "debug",
+			})
+		}),
+		chromedp.WithBrowserErrorf(func(s string, args ...any) {
+			debuglog.Failure(fmt.Sprintf(s, args), map[string]any{
+				"level": "error",
+			})
+		}),
+	}
+}
+
+// NewBrowserContext connects to the browser, and creates a new browser context in it.
+// It is used with a remote browser, to isolate cookies and storages from other executions that share the same browser.
+// The tabs should be made from the returned context, with the returned browser context ID.
+func NewBrowserContext(ctx context.Context, debuglog *ayd.Logger) (context.Context, context.CancelFunc, cdp.BrowserContextID, error) {
+	ctx, cancel := chromedp.NewContext(ctx)
+
+	// Allocate the connection by hand instead of chromedp.Run, because chromedp.Run opens an extra tab outside of the browser context.
+	c := chromedp.FromContext(ctx)
+	b, err := c.Allocator.Allocate(ctx, browserLogOptions(debuglog)...)
+	if err != nil {
+		cancel()
+		return nil, nil, "", err
+	}
+	c.Browser = b
+
+	id, err := target.CreateBrowserContext().WithDisposeOnDetach(true).Do(cdp.WithExecutor(ctx, b))
+	if err != nil {
+		cancel()
+		return nil, nil, "", err
+	}
+
+	return ctx, func() {
+		dctx, stop := context.WithTimeout(context.Background(), time.Second)
+		target.DisposeBrowserContext(id).Do(cdp.WithExecutor(dctx, b))
+		stop()
+		cancel()
+	}, id, nil
+}
+
 func NewContext(arg Arg, debuglog *ayd.Logger) (context.Context, context.CancelFunc) {
 	ctx := context.Background()
 
@@ -59,30 +126,9 @@ func NewContext(arg Arg, debuglog *ayd.Logger) (context.Context, context.CancelF
 		ctx, stopNotify = signal.NotifyContext(ctx, os.Interrupt)
 	}
 
-	ctx, stopAllocator := NewExecAllocator(ctx, arg.Head)
-
-	var opts []chromedp.ContextOption
-	if debuglog != nil {
-		opts = append(
-			opts,
-			chromedp.WithLogf(func(s string, args ...any) {
-				debuglog.Healthy(fmt.Sprintf(s, args), map[string]any{
-					"level": "log",
-				})
-			}),
-			chromedp.WithDebugf(func(s string, args ...any) {
-				debuglog.Healthy(fmt.Sprintf(s, args), map[string]any{
-					"level": "debug",
-				})
-			}),
-			chromedp.WithErrorf(func(s string, args ...any) {
-				debuglog.Failure(fmt.Sprintf(s, args), map[string]any{
-					"level": "error",
-				})
-			}),
-		)
-	}
-	ctx, stopBrowser := chromedp.NewContext(ctx, opts...)
+	ctx, stopAllocator := NewAllocator(ctx, arg)
+
+	ctx, stopBrowser := chromedp.NewContext(ctx, chromedp.WithBrowserOption(browserLogOptions(debuglog)...))
 
 	return ctx, func() {
 		stopBrowser()
@@ -100,6 +146,10 @@ func Run(arg Arg) ayd.Record {
 		logger.Stream = os.Stdout
 	}
 
+	if arg.BrowserURL == "" {
+		arg.BrowserURL = os.Getenv("WEBSCENARIO_BROWSER_URL")
+	}
+
 	baseDir := os.Getenv("WEBSCENARIO_ARTIFACT_DIR")
 	storage, err := NewStorage(arg.ArtifactDir(baseDir), timestamp)
 	if err != nil {
@@ -110,6 +160,15 @@ func Run(arg Arg) ayd.Record {
 		}
 	}
 
+	arg, err = LoadBrowserOptions(arg)
+	if err != nil {
+		return ayd.Record{
+			Time:    timestamp,
+			Status:  ayd.StatusFailure,
+			Message: err.Error(),
+		}
+	}
+
 	var browserlog *ayd.Logger
 	if arg.Debug {
 		f, err := storage.Open("browser.log")
@@ -128,8 +187,26 @@ func Run(arg Arg) ayd.Record {
 	ctx, cancel := NewContext(arg, browserlog)
 	defer cancel()
 
+	var browserContextID cdp.BrowserContextID
+	if arg.BrowserURL != "" {
+		var stop context.CancelFunc
+		ctx, stop, browserContextID, err = NewBrowserContext(ctx, browserlog)
+		if err != nil {
+			return ayd.Record{
+				Time:    timestamp,
+				Status:  ayd.StatusFailure,
+				Message: fmt.Sprintf("failed to connect to the browser: %s", err),
+			}
+		}
+		defer stop()
+	}
+
 	env := NewEnvironment(ctx, logger, storage, arg)
+	env.BrowserContextID = browserContextID
 	env.EnableRecording = arg.Recording
+	env.ScreencastFormat = arg.Screencast
+	env.EnableEvidence = arg.Evidence
+	env.FailOnHTTPError = arg.FailOnHTTPError
 
 	var latency time.Duration
 	switch arg.Mode {
@@ -143,6 +220,10 @@ func Run(arg Arg) ayd.Record {
 		latency = time.Since(stime)
 	}
 
+	if err != nil || logger.GetStatus() == ayd.StatusFailure {
+		env.SaveEvidence()
+	}
+
 	env.Close()
 	logger.HandleError(ctx, err)
 
@@ -1,11 +1,16 @@
 package webscenario
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/chromedp/chromedp"
 	"github.com/google/go-cmp/cmp"
 	"github.com/macrat/ayd/lib-ayd"
 )
@@ -179,3 +184,74 @@ func TestRun(t *testing.T) {
 		}
 	})
 }
+
+func TestRun_evidence(t *testing.T) {
+	server := StartTestServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	t.Setenv("TEST_URL", server.URL)
+	t.Setenv("WEBSCENARIO_ARTIFACT_DIR", dir)
+	t.Setenv("TEST_TEXT", "incorrect")
+
+	r := Run(Arg{
+		Mode:     "ayd",
+		Target:   &ayd.URL{Scheme: "web-scenario", Opaque: "./testdata/run-test.lua"},
+		Timeout:  5 * time.Minute,
+		Evidence: true,
+	})
+
+	if r.Status != ayd.StatusFailure {
+		t.Fatalf("expected FAILURE status but got %s", r.Status)
+	}
+
+	artifacts, _ := r.Extra["artifacts"].([]string)
+	for _, name := range []string{"tab1.png", "tab1.html", "tab1.log"} {
+		found := false
+		for _, a := range artifacts {
+			found = found || strings.HasSuffix(filepath.ToSlash(a), "/evidence/"+name)
+		}
+		if !found {
+			t.Errorf("%s is not found in artifacts: %v", name, artifacts)
+		}
+	}
+}
+
+func TestRun_browserURL(t *testing.T) {
+	server := StartTestServer()
+	defer server.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %s", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.Flag("remote-debugging-port", strconv.Itoa(port)))
+	ctx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	defer cancel()
+	ctx, cancel = chromedp.NewContext(ctx)
+	defer cancel()
+	if err := chromedp.Run(ctx); err != nil {
+		t.Fatalf("failed to start browser: %s", err)
+	}
+
+	t.Setenv("TEST_URL", server.URL)
+	t.Setenv("TEST_TEXT", "world")
+	t.Setenv("WEBSCENARIO_ARTIFACT_DIR", t.TempDir())
+	t.Setenv("WEBSCENARIO_BROWSER_URL", fmt.Sprintf("http://127.0.0.1:%d", port))
+
+	r := Run(Arg{
+		Mode:    "ayd",
+		Target:  &ayd.URL{Scheme: "web-scenario", Opaque: "./testdata/run-test.lua"},
+		Timeout: 5 * time.Minute,
+	})
+	if r.Status != ayd.StatusHealthy {
+		t.Errorf("expected HEALTHY status but got %s: %s", r.Status, r.Message)
+	}
+
+	if err := chromedp.Run(ctx, chromedp.Navigate(server.URL)); err != nil {
+		t.Errorf("the remote browser should keep running: %s", err)
+	}
+}
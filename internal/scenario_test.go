@@ -2,7 +2,11 @@ package webscenario
 
 import (
 	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -135,6 +139,15 @@ func StartTestServer() *httptest.Server {
 	mux.HandleFunc("/header", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "%s %q", r.Method, r.Header.Get("X-Header-Test"))
 	})
+	mux.HandleFunc("/referer", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Referer())
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/?target=redirect", http.StatusFound)
+	})
+	mux.HandleFunc("/redirect/error", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/error", http.StatusMovedPermanently)
+	})
 	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
 		io.Copy(w, r.Body)
 	})
@@ -145,6 +158,16 @@ func StartTestServer() *httptest.Server {
 		})
 		fmt.Fprint(w, "ok")
 	})
+	mux.HandleFunc("/cookie/strict", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "strict_test",
+			Value:    "hello strict",
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		fmt.Fprint(w, "ok")
+	})
 	mux.HandleFunc("/cookie/get", func(w http.ResponseWriter, r *http.Request) {
 		c, err := r.Cookie("cookie_test")
 		if err != nil {
@@ -154,6 +177,88 @@ func StartTestServer() *httptest.Server {
 		}
 	})
 
+	mux.HandleFunc("/indexeddb", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html")
+		fmt.Fprint(w, `
+			<script>
+				const req = indexedDB.open("testdb", 1);
+				req.onupgradeneeded = () => {
+					const store = req.result.createObjectStore("users", {keyPath: "id"});
+					store.put({id: 1, name: "alice", tags: ["admin"]});
+					store.put({id: 2, name: "bob", tags: []});
+				};
+				req.onsuccess = () => {
+					req.result.close();
+					document.body.innerHTML += '<div id="done">done</div>';
+				};
+			</script>
+		`)
+	})
+
+	mux.HandleFunc("/geolocation", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html")
+		fmt.Fprint(w, `
+			<script>
+				navigator.geolocation.getCurrentPosition(
+					(p) => { document.body.innerHTML += '<div id="position">' + p.coords.latitude + ',' + p.coords.longitude + '</div>'; },
+					(e) => { document.body.innerHTML += '<div id="position">' + e.message + '</div>'; },
+				);
+			</script>
+		`)
+	})
+	mux.HandleFunc("/accept-language", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Accept-Language"))
+	})
+
+	mux.HandleFunc("/console", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html")
+		fmt.Fprint(w, `
+			<button onclick="console.warn('clicked', {count: 1})">log</button>
+			<button id="throw" onclick="throw new Error('boom')">throw</button>
+			<script>
+				console.log('hello', 42);
+			</script>
+		`)
+	})
+
+	mux.HandleFunc("/actionability", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html")
+		fmt.Fprint(w, `
+			<button id="late" disabled onclick="this.textContent = 'clicked'">late</button>
+			<input id="hidden" style="visibility: hidden" value="hello">
+			<button id="never" disabled>never</button>
+			<div id="result"></div>
+			<script>
+				setTimeout(() => {
+					document.querySelector("#late").disabled = false;
+					document.querySelector("#hidden").style.visibility = "visible";
+				}, 300);
+				setTimeout(() => {
+					document.querySelector("#result").textContent = "appeared";
+					document.body.appendChild(Object.assign(document.createElement("p"), {id: "appear", textContent: "hello"}));
+				}, 1500);
+			</script>
+		`)
+	})
+
+	mux.HandleFunc("/frames", func(w http.ResponseWriter, r *http.Request) {
+		// localhost is another site from 127.0.0.1, so the remote frame is loaded in another process.
+		_, port, _ := net.SplitHostPort(r.Host)
+		w.Header().Set("content-type", "text/html")
+		fmt.Fprintf(w, `
+			<h1>top</h1>
+			<iframe name="local" src="/frames/child"></iframe>
+			<iframe id="remote" src="http://localhost:%s/frames/child"></iframe>
+		`, port)
+	})
+	mux.HandleFunc("/frames/child", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html")
+		fmt.Fprintf(w, `
+			<h1>%s</h1>
+			<input><button onclick="this.textContent = document.querySelector('input').value">send</button>
+			<iframe name="nested" src="/?target=nested"></iframe>
+		`, r.Host)
+	})
 	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, "something wrong!")
@@ -176,10 +281,96 @@ func RegisterTestUtil(L *lua.LState, storage *Storage, server *httptest.Server)
 			L.Push(lua.LString(filepath.Join(storage.Dir, L.OptString(1, ""))))
 			return 1
 		},
+		"sameGif": func(L *lua.LState) int {
+			if err := compareGifPixels(filepath.Join(storage.Dir, L.CheckString(1)), L.CheckString(2)); err != nil {
+				L.Push(lua.LFalse)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			L.Push(lua.LTrue)
+			return 1
+		},
+		"gif": func(L *lua.LState) int {
+			f, err := os.Open(filepath.Join(storage.Dir, L.CheckString(1)))
+			if err != nil {
+				L.RaiseError("%s", err)
+			}
+			defer f.Close()
+
+			g, err := gif.DecodeAll(f)
+			if err != nil {
+				L.RaiseError("%s", err)
+			}
+
+			delays := L.NewTable()
+			for _, d := range g.Delay {
+				delays.Append(lua.LNumber(d))
+			}
+			tbl := L.NewTable()
+			L.SetField(tbl, "frames", lua.LNumber(len(g.Image)))
+			L.SetField(tbl, "delays", delays)
+			L.Push(tbl)
+			return 1
+		},
 	})
 	L.SetGlobal("TEST", tbl)
 }
 
+// compareGifPixels compares the frames of two GIF files pixel by pixel, as they are rendered by viewers.
+// The delays are not compared, because they depend on the time of each action.
+func compareGifPixels(actualPath, wantPath string) error {
+	load := func(path string) ([]*image.RGBA, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		g, err := gif.DecodeAll(f)
+		if err != nil {
+			return nil, err
+		}
+
+		canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+		frames := make([]*image.RGBA, len(g.Image))
+		for i, img := range g.Image {
+			draw.Draw(canvas, img.Rect, img, img.Rect.Min, draw.Over)
+			frames[i] = image.NewRGBA(canvas.Rect)
+			copy(frames[i].Pix, canvas.Pix)
+			if g.Disposal[i] == gif.DisposalBackground {
+				draw.Draw(canvas, img.Rect, image.Transparent, image.ZP, draw.Src)
+			}
+		}
+		return frames, nil
+	}
+
+	actual, err := load(actualPath)
+	if err != nil {
+		return err
+	}
+	want, err := load(wantPath)
+	if err != nil {
+		return err
+	}
+
+	if len(actual) != len(want) {
+		return fmt.Errorf("expected %d frames but got %d frames", len(want), len(actual))
+	}
+	for i := range want {
+		if actual[i].Rect != want[i].Rect {
+			return fmt.Errorf("frame %d: expected size %v but got %v", i, want[i].Rect, actual[i].Rect)
+		}
+		for y := want[i].Rect.Min.Y; y < want[i].Rect.Max.Y; y++ {
+			for x := want[i].Rect.Min.X; x < want[i].Rect.Max.X; x++ {
+				if a, w := actual[i].RGBAAt(x, y), want[i].RGBAAt(x, y); a != w {
+					return fmt.Errorf("frame %d: expected %v at (%d, %d) but got %v", i, w, x, y, a)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 type DebugWriter testing.T
 
 func (w *DebugWriter) Write(b []byte) (int, error) {
@@ -218,7 +409,7 @@ func Test_testSenarios(t *testing.T) {
 			}
 
 			logger := &Logger{Stream: (*DebugWriter)(t)}
-			env := NewEnvironment(ctx, logger, s, Arg{Mode: "ayd", Args: []string{"abc", "def"}, Target: target})
+			env := NewEnvironment(ctx, logger, s, Arg{Mode: "ayd", Args: []string{"abc", "def"}, Target: target, DefaultTimeout: DefaultTimeout})
 			defer env.Close()
 
 			RegisterTestUtil(env.lua, s, server)
@@ -230,9 +421,7 @@ func Test_testSenarios(t *testing.T) {
 	}
 }
 
-func Test_errorInEvent(t *testing.T) {
-	t.Parallel()
-
+func newErrorTestEnvironment(t *testing.T) *Environment {
 	server := StartTestServer()
 	t.Cleanup(server.Close)
 
@@ -247,11 +436,19 @@ func Test_errorInEvent(t *testing.T) {
 	}
 
 	logger := &Logger{Stream: (*DebugWriter)(t)}
-	env := NewEnvironment(ctx, logger, s, Arg{Mode: "ayd", Args: []string{"abc", "def"}, Target: target})
-	defer env.Close()
+	env := NewEnvironment(ctx, logger, s, Arg{Mode: "ayd", Args: []string{"abc", "def"}, Target: target, DefaultTimeout: DefaultTimeout})
+	t.Cleanup(func() { env.Close() })
 
 	RegisterTestUtil(env.lua, s, server)
 
+	return env
+}
+
+func Test_errorInEvent(t *testing.T) {
+	t.Parallel()
+
+	env := newErrorTestEnvironment(t)
+
 	expect := `testdata/error-in-event.lua:4: test error
 stack traceback:
 	[G]: in function 'error'
@@ -264,3 +461,30 @@ stack traceback:
 		t.Fatalf("unexpected error:\n%s", err)
 	}
 }
+
+func Test_successInEvent(t *testing.T) {
+	t.Parallel()
+
+	env := newErrorTestEnvironment(t)
+
+	if err := env.DoFile("testdata/success-in-event.lua"); err != nil {
+		t.Fatalf("unexpected error:\n%s", err)
+	}
+	if v := env.lua.GetGlobal("finished"); v != lua.LTrue {
+		t.Fatalf("the scenario is stopped before finished")
+	}
+}
+
+func Test_failOnJSError(t *testing.T) {
+	t.Parallel()
+
+	env := newErrorTestEnvironment(t)
+
+	expect := "tab#1: uncaught exception: Error: boom\n"
+
+	if err := env.DoFile("testdata/js-error.lua"); err == nil {
+		t.Fatalf("expected error but got nil")
+	} else if !strings.HasPrefix(err.Error(), expect) {
+		t.Fatalf("unexpected error:\n%s", err)
+	}
+}
@@ -0,0 +1,423 @@
+package webscenario
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"golang.org/x/image/draw"
+)
+
+const (
+	ScreencastAPNG = "apng"
+	ScreencastHTML = "html"
+)
+
+// lastFrameDelay is the display time of the last frame of screencast.
+const lastFrameDelay = time.Second
+
+// apngMemoryLimit is the maximum total size of frames that are kept in memory for APNG, if maxBytes is not set.
+const apngMemoryLimit = 256 << 20
+
+type screencastFrame struct {
+	Time  time.Time
+	Data  []byte
+	Where string
+	ack   func()
+}
+
+// ScreencastRecorder records frames that the browser sends whenever the page is updated.
+// Unlike Recorder, it can record animations and asynchronous updates between actions.
+//
+// In APNG format, the frames are kept in memory as PNG and encoded at the end.
+// In HTML format, each frame is saved into the storage as soon as it is received, and a player is saved at the end.
+// Frames after reaching maxFrames or maxBytes are dropped.
+type ScreencastRecorder struct {
+	sync.Mutex
+
+	storage   *Storage
+	id        int
+	format    string
+	source    bool
+	maxFrames int
+	maxBytes  int64
+	size      int64
+
+	where  string
+	ch     chan screencastFrame
+	closed bool
+
+	frames []screencastFrame
+	files  []screencastFile
+
+	Done chan struct{}
+}
+
+type screencastFile struct {
+	Src  string `json:"src"`
+	Time int64  `json:"time"`
+}
+
+// NewScreencastRecorder makes a new recorder.
+// The maxFrames and maxBytes limit the size of the record. Zero means unlimited, but APNG is limited by apngMemoryLimit because it is kept in memory.
+func NewScreencastRecorder(storage *Storage, id int, format string, source bool, maxFrames int, maxBytes int64) *ScreencastRecorder {
+	if format == ScreencastAPNG && maxBytes <= 0 {
+		maxBytes = apngMemoryLimit
+	}
+	r := &ScreencastRecorder{
+		storage:   storage,
+		id:        id,
+		format:    format,
+		source:    source,
+		maxFrames: maxFrames,
+		maxBytes:  maxBytes,
+		ch:        make(chan screencastFrame, 32),
+		Done:      make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Start makes an action to start screencast.
+func (r *ScreencastRecorder) Start() *page.StartScreencastParams {
+	return page.StartScreencast().WithFormat(page.ScreencastFormatPng).WithEveryNthFrame(1)
+}
+
+// SetWhere sets the current position in the scenario, that is shown in the source-code panel.
+func (r *ScreencastRecorder) SetWhere(where string) {
+	r.Lock()
+	defer r.Unlock()
+	r.where = where
+}
+
+// Push adds a frame from the browser.
+// The ack is called after the frame processed, so that the browser doesn't send the next frame before that.
+// Push never blocks because it is called from the event listener of the tab. The frame is dropped if the queue is full.
+func (r *ScreencastRecorder) Push(e *page.EventScreencastFrame, ack func()) {
+	data, err := base64.StdEncoding.DecodeString(e.Data)
+	if err != nil {
+		go ack()
+		return
+	}
+	f := screencastFrame{Time: time.Now(), Data: data, ack: ack}
+	if e.Metadata != nil && e.Metadata.Timestamp != nil {
+		f.Time = e.Metadata.Timestamp.Time()
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if r.closed {
+		return
+	}
+	f.Where = r.where
+	select {
+	case r.ch <- f:
+	default:
+		go ack()
+	}
+}
+
+// Close stops receiving frames. The Done channel will be closed after all received frames are processed.
+func (r *ScreencastRecorder) Close() {
+	r.Lock()
+	defer r.Unlock()
+	if !r.closed {
+		r.closed = true
+		close(r.ch)
+	}
+}
+
+func (r *ScreencastRecorder) run() {
+	for f := range r.ch {
+		r.process(f)
+		f.ack()
+	}
+	close(r.Done)
+}
+
+// process stores a frame, or drops it if the record reached the limit.
+func (r *ScreencastRecorder) process(f screencastFrame) {
+	if r.maxFrames > 0 && len(r.frames)+len(r.files) >= r.maxFrames {
+		return
+	}
+
+	if r.source {
+		img, err := png.Decode(bytes.NewReader(f.Data))
+		if err != nil {
+			return
+		}
+		if f.Data, err = encodePNG(withSourcePanel(img, f.Where)); err != nil {
+			return
+		}
+	}
+
+	if r.maxBytes > 0 && r.size+int64(len(f.Data)) > r.maxBytes {
+		return
+	}
+	r.size += int64(len(f.Data))
+
+	if r.format == ScreencastHTML {
+		n := len(r.files) + 1
+		if _, err := r.storage.Save(fmt.Sprintf("record%d/%06d", r.id, n), ".png", f.Data); err == nil {
+			r.files = append(r.files, screencastFile{
+				Src:  fmt.Sprintf("record%d/%06d.png", r.id, n),
+				Time: f.Time.UnixMilli(),
+			})
+		}
+	} else {
+		r.frames = append(r.frames, f)
+	}
+}
+
+// withSourcePanel draws the source code around the where next to the screen image.
+func withSourcePanel(screen image.Image, where string) image.Image {
+	b := screen.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx()+SourceWidth, b.Dy()))
+	draw.Draw(img, img.Rect, image.Black, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, b.Dx(), b.Dy()), screen, b.Min, draw.Src)
+	if where != "" {
+		path, line := parseWhere(where)
+		sourceImager.LoadAsImage(img, image.Rect(b.Dx(), 0, img.Rect.Max.X, b.Dy()), path, line)
+	}
+	return img
+}
+
+// Save writes the recorded frames into the storage.
+// It has to be called after the Done channel closed.
+func (r *ScreencastRecorder) Save() error {
+	if len(r.frames) == 0 && len(r.files) == 0 {
+		return NoRecord
+	}
+
+	if r.format == ScreencastHTML {
+		var buf bytes.Buffer
+		if err := writeScreencastPlayer(&buf, fmt.Sprintf("record%d", r.id), r.files); err != nil {
+			return err
+		}
+		_, err := r.storage.Save(fmt.Sprintf("record%d", r.id), ".html", buf.Bytes())
+		return err
+	}
+
+	f, err := r.storage.Open(fmt.Sprintf("record%d.png", r.id))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return encodeAPNG(f, r.frames)
+}
+
+var screencastPlayer = template.Must(template.New("player").Parse(`<!DOCTYPE html>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { margin: 0; background: #222; color: #eee; font-family: sans-serif; }
+img { display: block; max-width: 100%; }
+nav { display: flex; gap: 8px; align-items: center; padding: 8px; }
+input { flex: 1; }
+</style>
+<img id="screen" alt="">
+<nav>
+<button id="play">pause</button>
+<input id="seek" type="range" min="0" value="0">
+<span id="time"></span>
+</nav>
+<script>
+const frames = {{ .Frames }};
+const screen = document.getElementById("screen");
+const play = document.getElementById("play");
+const seek = document.getElementById("seek");
+const time = document.getElementById("time");
+seek.max = frames.length - 1;
+let index = 0, timer = null;
+const show = (i) => {
+	index = i;
+	seek.value = i;
+	screen.src = frames[i].src;
+	time.textContent = ((frames[i].time - frames[0].time) / 1000).toFixed(2) + "s";
+};
+const next = () => {
+	if (index + 1 >= frames.length) {
+		stop();
+		return;
+	}
+	timer = setTimeout(() => { show(index + 1); next(); }, frames[index + 1].time - frames[index].time);
+};
+const stop = () => {
+	clearTimeout(timer);
+	timer = null;
+	play.textContent = "play";
+};
+play.onclick = () => {
+	if (timer !== null) {
+		stop();
+	} else {
+		if (index + 1 >= frames.length) show(0);
+		play.textContent = "pause";
+		next();
+	}
+};
+seek.oninput = () => { stop(); show(Number(seek.value)); };
+show(0);
+next();
+</script>
+`))
+
+func writeScreencastPlayer(w io.Writer, title string, files []screencastFile) error {
+	frames, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	return screencastPlayer.Execute(w, map[string]any{
+		"Title":  title,
+		"Frames": template.JS(frames),
+	})
+}
+
+// pngChunk is a chunk in PNG file.
+type pngChunk struct {
+	Type string
+	Data []byte
+}
+
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, errors.New("invalid PNG signature")
+	}
+	data = data[len(signature):]
+
+	var chunks []pngChunk
+	for len(data) >= 12 {
+		size := binary.BigEndian.Uint32(data)
+		if uint64(len(data)) < 12+uint64(size) {
+			return nil, errors.New("broken PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{
+			Type: string(data[4:8]),
+			Data: data[8 : 8+size],
+		})
+		data = data[12+size:]
+	}
+	return chunks, nil
+}
+
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	buf := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], typ)
+	buf = append(buf, data...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+	_, err := w.Write(buf)
+	return err
+}
+
+// encodeAPNG writes frames as an animated PNG.
+// Each frame is shown until the time of the next frame.
+func encodeAPNG(w io.Writer, frames []screencastFrame) error {
+	var width, height int
+	for _, f := range frames {
+		c, err := png.DecodeConfig(bytes.NewReader(f.Data))
+		if err != nil {
+			return err
+		}
+		if c.Width > width {
+			width = c.Width
+		}
+		if c.Height > height {
+			height = c.Height
+		}
+	}
+
+	if _, err := w.Write([]byte("\x89PNG\r\n\x1a\n")); err != nil {
+		return err
+	}
+
+	seq := uint32(0)
+	var ihdr []byte
+	for i, f := range frames {
+		src, err := png.Decode(bytes.NewReader(f.Data))
+		if err != nil {
+			return err
+		}
+		// Draw on an opaque canvas so that all frames are encoded in the same color type.
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(img, img.Rect, &image.Uniform{color.Black}, image.Point{}, draw.Src)
+		draw.Draw(img, img.Rect, src, src.Bounds().Min, draw.Src)
+
+		raw, err := encodePNG(img)
+		if err != nil {
+			return err
+		}
+		chunks, err := readPNGChunks(raw)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			ihdr = chunks[0].Data
+			if err := writePNGChunk(w, "IHDR", ihdr); err != nil {
+				return err
+			}
+			actl := binary.BigEndian.AppendUint32(nil, uint32(len(frames)))
+			actl = binary.BigEndian.AppendUint32(actl, 0)
+			if err := writePNGChunk(w, "acTL", actl); err != nil {
+				return err
+			}
+		} else if !bytes.Equal(ihdr, chunks[0].Data) {
+			return errors.New("inconsistent frame format")
+		}
+
+		delay := lastFrameDelay
+		if i+1 < len(frames) {
+			delay = frames[i+1].Time.Sub(f.Time)
+		}
+		ms := delay.Milliseconds()
+		if ms < 0 {
+			ms = 0
+		} else if ms > 0xFFFF {
+			ms = 0xFFFF
+		}
+
+		fctl := binary.BigEndian.AppendUint32(nil, seq)
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(width))
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(height))
+		fctl = binary.BigEndian.AppendUint32(fctl, 0)
+		fctl = binary.BigEndian.AppendUint32(fctl, 0)
+		fctl = binary.BigEndian.AppendUint16(fctl, uint16(ms))
+		fctl = binary.BigEndian.AppendUint16(fctl, 1000)
+		fctl = append(fctl, 0, 0) // dispose_op = none, blend_op = source
+		seq++
+		if err := writePNGChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+
+		for _, c := range chunks {
+			if c.Type != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				err = writePNGChunk(w, "IDAT", c.Data)
+			} else {
+				err = writePNGChunk(w, "fdAT", append(binary.BigEndian.AppendUint32(nil, seq), c.Data...))
+				seq++
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return writePNGChunk(w, "IEND", nil)
+}
@@ -0,0 +1,144 @@
+package webscenario
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+)
+
+func TestEncodeAPNG(t *testing.T) {
+	t.Parallel()
+
+	frame := func(w, h int, c color.Color, at time.Duration) screencastFrame {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		raw, err := encodePNG(img)
+		if err != nil {
+			t.Fatalf("failed to encode frame: %s", err)
+		}
+		return screencastFrame{Time: time.Unix(0, 0).Add(at), Data: raw}
+	}
+
+	frames := []screencastFrame{
+		frame(10, 20, color.RGBA{255, 0, 0, 255}, 0),
+		frame(30, 15, color.RGBA{0, 255, 0, 255}, 250*time.Millisecond),
+		frame(30, 15, color.RGBA{0, 0, 255, 255}, 400*time.Millisecond),
+	}
+
+	var buf bytes.Buffer
+	if err := encodeAPNG(&buf, frames); err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to decode as PNG: %s", err)
+	}
+	if b := img.Bounds(); b.Dx() != 30 || b.Dy() != 20 {
+		t.Errorf("unexpected size: %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r>>8 != 255 {
+		t.Errorf("the default image should be the first frame")
+	}
+
+	chunks, err := readPNGChunks(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to read chunks: %s", err)
+	}
+	var types []string
+	var delays []uint16
+	for _, c := range chunks {
+		types = append(types, c.Type)
+		switch c.Type {
+		case "acTL":
+			if n := binary.BigEndian.Uint32(c.Data); n != 3 {
+				t.Errorf("unexpected number of frames: %d", n)
+			}
+		case "fcTL":
+			delays = append(delays, binary.BigEndian.Uint16(c.Data[20:]))
+		}
+	}
+	if s := strings.Join(types, " "); s != "IHDR acTL fcTL IDAT fcTL fdAT fcTL fdAT IEND" {
+		t.Errorf("unexpected chunks: %s", s)
+	}
+	if len(delays) != 3 || delays[0] != 250 || delays[1] != 150 || delays[2] != 1000 {
+		t.Errorf("unexpected delays: %v", delays)
+	}
+}
+
+func TestScreencastRecorder_limit(t *testing.T) {
+	t.Parallel()
+
+	raw, err := encodePNG(image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	if err != nil {
+		t.Fatalf("failed to encode frame: %s", err)
+	}
+	ev := &page.EventScreencastFrame{Data: base64.StdEncoding.EncodeToString(raw)}
+
+	tests := []struct {
+		Name      string
+		MaxFrames int
+		MaxBytes  int64
+		Want      int
+	}{
+		{"unlimited", 0, 0, 100},
+		{"frames", 3, 0, 3},
+		{"bytes", 0, int64(len(raw))*5 + 1, 5},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+
+			r := NewScreencastRecorder(nil, 1, ScreencastAPNG, false, tt.MaxFrames, tt.MaxBytes)
+
+			// Push must not block even if the queue is full, and all frames must be acked.
+			var wg sync.WaitGroup
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				r.Push(ev, wg.Done)
+			}
+			r.Close()
+			<-r.Done
+			wg.Wait()
+
+			if tt.Want == 100 && len(r.frames) == 0 {
+				t.Errorf("no frame recorded")
+			} else if tt.Want < 100 && len(r.frames) != tt.Want {
+				t.Errorf("expected %d frames but got %d", tt.Want, len(r.frames))
+			}
+		})
+	}
+}
+
+func TestWriteScreencastPlayer(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := writeScreencastPlayer(&buf, "record1", []screencastFile{
+		{Src: "record1/000001.png", Time: 1000},
+		{Src: "record1/000002.png", Time: 1500},
+	})
+	if err != nil {
+		t.Fatalf("failed to write: %s", err)
+	}
+
+	want := `const frames = [{"src":"record1/000001.png","time":1000},{"src":"record1/000002.png","time":1500}];`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("frames not found in the player:\n%s", buf.String())
+	}
+}
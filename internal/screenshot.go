@@ -0,0 +1,207 @@
+package webscenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// ScreenshotOptions is a set of options for tab:screenshot() and element:screenshot().
+type ScreenshotOptions struct {
+	FullPage       bool
+	Clip           *documentRect
+	Format         page.CaptureScreenshotFormat
+	Quality        int
+	OmitBackground bool
+	Scale          float64
+}
+
+// DefaultScreenshotOptions makes options to take a PNG screenshot of the viewport.
+func DefaultScreenshotOptions() ScreenshotOptions {
+	return ScreenshotOptions{
+		Format:  page.CaptureScreenshotFormatPng,
+		Quality: -1,
+		Scale:   1,
+	}
+}
+
+// UnpackScreenshotOptions reads options from a Lua table.
+func UnpackScreenshotOptions(L *lua.LState, tbl *lua.LTable) (ScreenshotOptions, error) {
+	o := DefaultScreenshotOptions()
+
+	o.FullPage = lua.LVAsBool(L.GetField(tbl, "fullPage"))
+	o.OmitBackground = lua.LVAsBool(L.GetField(tbl, "omitBackground"))
+
+	switch c := L.GetField(tbl, "clip").(type) {
+	case *lua.LNilType:
+	case *lua.LTable:
+		var r documentRect
+		for name, dst := range map[string]*float64{"x": &r.X, "y": &r.Y, "width": &r.Width, "height": &r.Height} {
+			n, ok := L.GetField(c, name).(lua.LNumber)
+			if !ok {
+				return o, errors.New("clip field requires x, y, width, and height.")
+			}
+			*dst = float64(n)
+		}
+		if r.Width <= 0 || r.Height <= 0 {
+			return o, errors.New("width and height of clip should be greater than 0.")
+		}
+		o.Clip = &r
+	default:
+		return o, errors.New("clip field expected table value.")
+	}
+	if o.FullPage && o.Clip != nil {
+		return o, errors.New("fullPage and clip can not be used at the same time.")
+	}
+
+	switch f := L.GetField(tbl, "format").(type) {
+	case *lua.LNilType:
+	case lua.LString:
+		switch f {
+		case "png":
+			o.Format = page.CaptureScreenshotFormatPng
+		case "jpeg", "jpg":
+			o.Format = page.CaptureScreenshotFormatJpeg
+		case "webp":
+			o.Format = page.CaptureScreenshotFormatWebp
+		default:
+			return o, errors.New(`format field expected "png", "jpeg", or "webp".`)
+		}
+	default:
+		return o, errors.New("format field expected string value.")
+	}
+
+	switch q := L.GetField(tbl, "quality").(type) {
+	case *lua.LNilType:
+	case lua.LNumber:
+		if q < 0 || q > 100 {
+			return o, errors.New("quality field expected number between 0 and 100.")
+		}
+		if o.Format == page.CaptureScreenshotFormatPng {
+			return o, errors.New("quality field can not be used with png format.")
+		}
+		o.Quality = int(q)
+	default:
+		return o, errors.New("quality field expected number value.")
+	}
+
+	switch s := L.GetField(tbl, "scale").(type) {
+	case *lua.LNilType:
+	case lua.LNumber:
+		if s <= 0 {
+			return o, errors.New("scale field should be greater than 0.")
+		}
+		o.Scale = float64(s)
+	default:
+		return o, errors.New("scale field expected number value.")
+	}
+
+	return o, nil
+}
+
+// Ext returns the file extension for the format.
+func (o ScreenshotOptions) Ext() string {
+	switch o.Format {
+	case page.CaptureScreenshotFormatJpeg:
+		return ".jpg"
+	case page.CaptureScreenshotFormatWebp:
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
+
+// clip decides the area to capture in document coordinates.
+// The nodeID is used as the area if it is not nil.
+func (o ScreenshotOptions) clip(ctx context.Context, nodeID *cdp.NodeID) (documentRect, error) {
+	switch {
+	case nodeID != nil:
+		return nodeRect(ctx, *nodeID)
+	case o.Clip != nil:
+		return *o.Clip, nil
+	case o.FullPage:
+		_, _, contentSize, _, _, cssContentSize, err := page.GetLayoutMetrics().Do(ctx)
+		if err != nil {
+			return documentRect{}, err
+		}
+		if cssContentSize != nil {
+			contentSize = cssContentSize
+		}
+		return documentRect{0, 0, contentSize.Width, contentSize.Height}, nil
+	default:
+		var r documentRect
+		err := chromedp.Evaluate(viewportRectScript, &r).Do(ctx)
+		return r, err
+	}
+}
+
+// Capture makes an action to take a screenshot of the page, or the node if nodeID is not nil.
+func (o ScreenshotOptions) Capture(nodeID *cdp.NodeID, buf *[]byte) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		r, err := o.clip(ctx, nodeID)
+		if err != nil {
+			return err
+		}
+		if r.Width <= 0 || r.Height <= 0 {
+			return fmt.Errorf("can not take screenshot of empty area: %gx%g", r.Width, r.Height)
+		}
+
+		if o.OmitBackground {
+			err := emulation.SetDefaultBackgroundColorOverride().WithColor(&cdp.RGBA{R: 0, G: 0, B: 0, A: 0}).Do(ctx)
+			if err != nil {
+				return err
+			}
+			defer emulation.SetDefaultBackgroundColorOverride().Do(ctx)
+		}
+
+		p := page.CaptureScreenshot().
+			WithFormat(o.Format).
+			WithCaptureBeyondViewport(true).
+			WithClip(&page.Viewport{X: r.X, Y: r.Y, Width: r.Width, Height: r.Height, Scale: o.Scale})
+		if o.Quality >= 0 {
+			p = p.WithQuality(int64(o.Quality))
+		}
+		*buf, err = p.Do(ctx)
+		return err
+	})
+}
+
+// checkNameAndOption reads the arguments like ([name], [option]) or (option), that are used by the methods to save a file.
+// The opts is nil if the option is not given, and the pos is the position of the option in the arguments.
+func checkNameAndOption(L *lua.LState) (name string, opts *lua.LTable, pos int) {
+	pos = 2
+	if _, ok := L.Get(2).(*lua.LTable); !ok {
+		name = L.ToString(2)
+		pos = 3
+	}
+
+	switch v := L.Get(pos).(type) {
+	case *lua.LNilType:
+	case *lua.LTable:
+		opts = v
+	default:
+		L.ArgError(pos, "table expected.")
+	}
+
+	return name, opts, pos
+}
+
+// checkScreenshotArgs reads the arguments of screenshot methods, that is ([name], [option]) or (option).
+// The option is nil if it is not given.
+func checkScreenshotArgs(L *lua.LState) (name string, opts *ScreenshotOptions) {
+	name, tbl, pos := checkNameAndOption(L)
+	if tbl != nil {
+		o, err := UnpackScreenshotOptions(L, tbl)
+		if err != nil {
+			L.ArgError(pos, err.Error())
+		}
+		opts = &o
+	}
+	return name, opts
+}
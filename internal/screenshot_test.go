@@ -0,0 +1,61 @@
+package webscenario
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_checkNameAndOption(t *testing.T) {
+	tests := []struct {
+		Script string
+		Name   string
+		Option bool
+		Pos    int
+		Error  string
+	}{
+		{`return f(nil)`, "", false, 3, ""},
+		{`return f(nil, "foo")`, "foo", false, 3, ""},
+		{`return f(nil, {})`, "", true, 2, ""},
+		{`return f(nil, "foo", {})`, "foo", true, 3, ""},
+		{`return f(nil, "foo", "bar")`, "", false, 0, "bad argument #3"},
+		{`return f(nil, {}, "bar")`, "", true, 2, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Script, func(t *testing.T) {
+			L := lua.NewState()
+			defer L.Close()
+
+			var name string
+			var opts *lua.LTable
+			var pos int
+			L.SetGlobal("f", L.NewFunction(func(L *lua.LState) int {
+				name, opts, pos = checkNameAndOption(L)
+				return 0
+			}))
+
+			err := L.DoString(tt.Script)
+			if tt.Error != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Error) {
+					t.Fatalf("expected error %q but got %v", tt.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if name != tt.Name {
+				t.Errorf("expected name %q but got %q", tt.Name, name)
+			}
+			if (opts != nil) != tt.Option {
+				t.Errorf("expected option %v but got %v", tt.Option, opts)
+			}
+			if pos != tt.Pos {
+				t.Errorf("expected position %d but got %d", tt.Pos, pos)
+			}
+		})
+	}
+}
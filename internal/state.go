@@ -0,0 +1,213 @@
+package webscenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/domstorage"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultStateTTL is the lifetime of a saved state if the scenario doesn't specify it.
+const DefaultStateTTL = 24 * time.Hour
+
+// TabState is a snapshot of cookies and Web Storage of a tab.
+type TabState struct {
+	Expires        time.Time                    `json:"expires"`
+	Cookies        []*network.Cookie            `json:"cookies"`
+	LocalStorage   map[string]map[string]string `json:"localStorage,omitempty"`
+	SessionStorage map[string]map[string]string `json:"sessionStorage,omitempty"`
+}
+
+// validFileName reports whether the name is safe to use as a file name such as a state or a baseline image.
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// LoadTabState reads a state from the path.
+// It returns false if the state doesn't exist, is broken, or is already expired.
+func LoadTabState(path string, now time.Time) (TabState, bool) {
+	var s TabState
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return s, false
+	}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return s, false
+	}
+	if !s.Expires.After(now) {
+		return s, false
+	}
+	return s, true
+}
+
+// Save writes the state into the path.
+func (s TabState) Save(path string) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0600)
+}
+
+// Origins returns the origins that have Web Storage data in the state.
+func (s TabState) Origins() []string {
+	var origins []string
+	for o := range s.LocalStorage {
+		origins = append(origins, o)
+	}
+	for o := range s.SessionStorage {
+		if _, ok := s.LocalStorage[o]; !ok {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// seedScript makes a JavaScript to restore Web Storage of the origin.
+// The script does nothing if it runs on the other origin.
+func (s TabState) seedScript(origin string) string {
+	o, _ := json.Marshal(origin)
+	local, _ := json.Marshal(s.LocalStorage[origin])
+	session, _ := json.Marshal(s.SessionStorage[origin])
+
+	return fmt.Sprintf(`(function(origin, local, session) {
+	if (location.origin !== origin) return;
+	for (const k in local || {}) localStorage.setItem(k, local[k]);
+	for (const k in session || {}) sessionStorage.setItem(k, session[k]);
+})(%s, %s, %s)`, o, local, session)
+}
+
+func storageItems(ctx context.Context, origin string, isLocal bool) (map[string]string, error) {
+	items, err := domstorage.GetDOMStorageItems(&domstorage.StorageID{
+		SecurityOrigin: origin,
+		IsLocalStorage: isLocal,
+	}).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string)
+	for _, item := range items {
+		if len(item) == 2 {
+			m[item[0]] = item[1]
+		}
+	}
+	return m, nil
+}
+
+// captureState makes a snapshot of all cookies in the browser and Web Storage of the current page.
+func captureState(ctx context.Context, ttl time.Duration) (TabState, error) {
+	s := TabState{
+		Expires:        time.Now().Add(ttl),
+		LocalStorage:   make(map[string]map[string]string),
+		SessionStorage: make(map[string]map[string]string),
+	}
+
+	var err error
+	s.Cookies, err = network.GetAllCookies().Do(ctx)
+	if err != nil {
+		return s, err
+	}
+
+	origin, err := pageOrigin(ctx)
+	if err != nil {
+		// The page such as about:blank has no Web Storage.
+		return s, nil
+	}
+
+	if s.LocalStorage[origin], err = storageItems(ctx, origin, true); err != nil {
+		return s, err
+	}
+	if s.SessionStorage[origin], err = storageItems(ctx, origin, false); err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+// stateSeeds keeps scripts to restore Web Storage that are waiting for the page of the origin.
+type stateSeeds struct {
+	sync.Mutex
+
+	scripts map[string]page.ScriptIdentifier
+}
+
+func (s *stateSeeds) Add(origin string, id page.ScriptIdentifier) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.scripts == nil {
+		s.scripts = make(map[string]page.ScriptIdentifier)
+	}
+	s.scripts[origin] = id
+}
+
+// Pop removes and returns the script for the origin.
+func (s *stateSeeds) Pop(origin string) (page.ScriptIdentifier, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	id, ok := s.scripts[origin]
+	delete(s.scripts, origin)
+	return id, ok
+}
+
+// restoreState sets cookies and Web Storage in the state into the browser.
+// Web Storage of the current origin is restored immediately, and others are restored when the tab opens a page of the origin.
+func (t *Tab) restoreState(ctx context.Context, s TabState) error {
+	if len(s.Cookies) > 0 {
+		ps := make([]*network.CookieParam, len(s.Cookies))
+		for i, c := range s.Cookies {
+			u, h := splitBrowserCookie(c)
+			ps[i] = CookieToBrowser(h, u.String())
+		}
+		if err := network.SetCookies(ps).Do(ctx); err != nil {
+			return err
+		}
+	}
+
+	current, _ := pageOrigin(ctx)
+
+	for _, origin := range s.Origins() {
+		if origin == current {
+			if err := chromedp.Evaluate(s.seedScript(origin), nil).Do(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		id, err := page.AddScriptToEvaluateOnNewDocument(s.seedScript(origin)).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if old, ok := t.seeds.Pop(origin); ok {
+			if err := page.RemoveScriptToEvaluateOnNewDocument(old).Do(ctx); err != nil {
+				return err
+			}
+		}
+		t.seeds.Add(origin, id)
+	}
+
+	return nil
+}
+
+func (t *Tab) statePath(name string) (string, error) {
+	if !validFileName(name) {
+		return "", errors.New("invalid state name")
+	}
+	return filepath.Join(t.env.storage.StateDir, name+".json"), nil
+}
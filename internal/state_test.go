@@ -0,0 +1,84 @@
+package webscenario
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func Test_validFileName(t *testing.T) {
+	tests := []struct {
+		Name string
+		OK   bool
+	}{
+		{"login", true},
+		{"login.admin", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../login", false},
+		{"foo/bar", false},
+		{`foo\bar`, false},
+	}
+
+	for _, tt := range tests {
+		if ok := validFileName(tt.Name); ok != tt.OK {
+			t.Errorf("%q: expected %v but got %v", tt.Name, tt.OK, ok)
+		}
+	}
+}
+
+func TestTabState(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 1, 2, 15, 4, 5, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "state", "login.json")
+
+	if _, ok := LoadTabState(path, now); ok {
+		t.Fatalf("state should not exist yet")
+	}
+
+	s := TabState{
+		Expires: now.Add(time.Hour),
+		Cookies: []*network.Cookie{{
+			Name:    "session",
+			Value:   "hello",
+			Domain:  "example.com",
+			Path:    "/",
+			Session: true,
+
+			Priority:     network.CookiePriorityMedium,
+			SourceScheme: network.CookieSourceSchemeSecure,
+		}},
+		LocalStorage: map[string]map[string]string{
+			"https://example.com": {"token": "abc"},
+		},
+		SessionStorage: map[string]map[string]string{
+			"https://example.com": {"flag": "on"},
+			"https://example.org": {"flag": "off"},
+		},
+	}
+	if err := s.Save(path); err != nil {
+		t.Fatalf("failed to save state: %s", err)
+	}
+
+	loaded, ok := LoadTabState(path, now)
+	if !ok {
+		t.Fatalf("failed to load state")
+	}
+	if len(loaded.Cookies) != 1 || loaded.Cookies[0].Name != "session" || loaded.Cookies[0].Value != "hello" {
+		t.Errorf("unexpected cookies: %v", loaded.Cookies)
+	}
+	if loaded.LocalStorage["https://example.com"]["token"] != "abc" {
+		t.Errorf("unexpected local storage: %v", loaded.LocalStorage)
+	}
+	if len(loaded.Origins()) != 2 {
+		t.Errorf("unexpected origins: %v", loaded.Origins())
+	}
+
+	if _, ok := LoadTabState(path, now.Add(time.Hour)); ok {
+		t.Errorf("expired state should not be loaded")
+	}
+}
@@ -15,10 +15,12 @@ import (
 type Storage struct {
 	sync.Mutex
 
-	Dir       string
-	artifacts []string
-	guids     map[string]string
-	autoid    int
+	Dir         string
+	StateDir    string
+	BaselineDir string
+	artifacts   []string
+	guids       map[string]string
+	autoid      int
 }
 
 func NewStorage(baseDir string, timestamp time.Time) (*Storage, error) {
@@ -33,8 +35,10 @@ func NewStorage(baseDir string, timestamp time.Time) (*Storage, error) {
 	}
 
 	return &Storage{
-		Dir:   dir,
-		guids: make(map[string]string),
+		Dir:         dir,
+		StateDir:    filepath.Join(filepath.Dir(dir), "state"),
+		BaselineDir: filepath.Join(filepath.Dir(dir), "baseline"),
+		guids:       make(map[string]string),
 	}, nil
 }
 
@@ -71,7 +75,9 @@ func (s *Storage) Remove(path string) error {
 	return os.ErrNotExist
 }
 
-func (s *Storage) Save(name, ext string, data []byte) error {
+// Save writes data as an artifact, and returns the path to the saved file.
+// If the name is empty, it uses a serial number as the name.
+func (s *Storage) Save(name, ext string, data []byte) (string, error) {
 	s.Lock()
 
 	if name == "" {
@@ -88,9 +94,9 @@ func (s *Storage) Save(name, ext string, data []byte) error {
 	s.Unlock()
 
 	if err := s.mkdir(p); err != nil {
-		return err
+		return "", err
 	}
-	return os.WriteFile(p, data, 0644)
+	return p, os.WriteFile(p, data, 0644)
 }
 
 func (s *Storage) StartDownload(guid, name string) {
@@ -16,5 +16,9 @@ func TestNewStorage(t *testing.T) {
 		t.Errorf("failed to create storage: %s", err)
 	} else if s.Dir != filepath.Join(tmpdir, "20220102T150405") {
 		t.Errorf("unexpected storage directory: %s", s.Dir)
+	} else if s.StateDir != filepath.Join(tmpdir, "state") {
+		t.Errorf("unexpected state directory: %s", s.StateDir)
+	} else if s.BaselineDir != filepath.Join(tmpdir, "baseline") {
+		t.Errorf("unexpected baseline directory: %s", s.BaselineDir)
 	}
 }
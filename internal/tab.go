@@ -6,13 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/chromedp/cdproto"
 	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/cdproto/target"
 	"github.com/chromedp/chromedp"
 	"github.com/chromedp/chromedp/device"
 	"github.com/yuin/gopher-lua"
@@ -54,22 +59,56 @@ type Tab struct {
 	env    *Environment
 
 	loading *LoadWaiter
-
-	id            int
-	width, height int64
-	dialogEvent   *EventHandler
-	downloadEvent *EventHandler
-	requestEvent  *EventHandler
-	responseEvent *EventHandler
-
-	recorder *Recorder
+	network *NetworkTracker
+
+	id             int
+	viewport       device.Info
+	emulation      TabEmulation
+	dialogEvent    *EventHandler
+	downloadEvent  *EventHandler
+	requestEvent   *EventHandler
+	responseEvent  *EventHandler
+	consoleEvent   *EventHandler
+	exceptionEvent *EventHandler
+	router         *Router
+	seeds          stateSeeds
+	throttling     *NetworkConditions
+	har            *HARRecorder
+	harSaved       bool // skips saving HAR on close, because it is already saved by saveHAR.
+	networkIdle    bool // keeps the network events enabled to track in-flight requests, after waitNetworkIdle used once.
+	failOnJSError  bool
+	timeout        time.Duration
+
+	failOnHTTPError bool
+
+	performanceEnabled bool
+	performanceMu      sync.Mutex
+	metricsExtra       string
+
+	recorder   *Recorder
+	screencast *ScreencastRecorder
+	activity   *ActivityLog
+
+	main            *Frame
+	frameContexts   map[target.ID]frameSession
+	frameContextsMu sync.Mutex
 }
 
 func NewTab(ctx context.Context, L *lua.LState, env *Environment, id int) *Tab {
 	url := ""
-	width, height := int64(800), int64(800)
-	userAgent := ""
-	recording := false
+	viewport := device.Info{Width: 800, Height: 800, Scale: 1}
+	recording := ""
+	recordingSource := false
+	recordingMaxFrames := 0
+	var recordingMaxBytes int64
+	var cookiejar *CookieJar
+	state := ""
+	var emulation TabEmulation
+	metricsExtra := ""
+	var har *HARRecorder
+	failOnJSError := false
+	failOnHTTPError := env.FailOnHTTPError
+	timeout := env.DefaultTimeout
 
 	switch v := L.Get(1).(type) {
 	case lua.LString:
@@ -78,56 +117,204 @@ func NewTab(ctx context.Context, L *lua.LState, env *Environment, id int) *Tab {
 		if u, ok := L.GetField(v, "url").(lua.LString); ok {
 			url = string(u)
 		}
+		switch d := L.GetField(v, "device").(type) {
+		case *lua.LNilType:
+		case lua.LString:
+			var ok bool
+			if viewport, ok = LookupDevice(string(d)); !ok {
+				L.ArgError(1, fmt.Sprintf("unknown device: %q", string(d)))
+			}
+		default:
+			L.ArgError(1, "device field expected string value.")
+		}
 		if w, ok := L.GetField(v, "width").(lua.LNumber); ok {
-			width = int64(w)
+			viewport.Width = int64(w)
 		}
 		if h, ok := L.GetField(v, "height").(lua.LNumber); ok {
-			height = int64(h)
+			viewport.Height = int64(h)
 		}
 		if ua, ok := L.GetField(v, "useragent").(lua.LString); ok {
-			userAgent = string(ua)
+			viewport.UserAgent = string(ua)
+		}
+		switch r := L.GetField(v, "recording").(type) {
+		case *lua.LNilType:
+		case lua.LBool:
+			if r {
+				recording = "gif"
+			}
+		case lua.LString:
+			recording = string(r)
+		case *lua.LTable:
+			recording = "gif"
+			if f, ok := L.GetField(r, "format").(lua.LString); ok {
+				recording = string(f)
+			}
+			recordingSource = lua.LVAsBool(L.GetField(r, "source"))
+			if n, ok := L.GetField(r, "maxFrames").(lua.LNumber); ok {
+				recordingMaxFrames = int(n)
+			}
+			if n, ok := L.GetField(r, "maxBytes").(lua.LNumber); ok {
+				recordingMaxBytes = int64(n)
+			}
+		default:
+			L.ArgError(1, "recording field expected boolean, string, or table value.")
+		}
+		switch recording {
+		case "", "gif", ScreencastAPNG, ScreencastHTML:
+		default:
+			L.ArgError(1, `recording format expected "gif", "apng", or "html".`)
+		}
+		switch j := L.GetField(v, "cookiejar").(type) {
+		case *lua.LNilType:
+		case *lua.LUserData:
+			if cookiejar, _ = j.Value.(*CookieJar); cookiejar == nil {
+				L.ArgError(1, "cookiejar field expected cookiejar value.")
+			}
+		default:
+			L.ArgError(1, "cookiejar field expected cookiejar value.")
+		}
+		switch s := L.GetField(v, "state").(type) {
+		case *lua.LNilType:
+		case lua.LString:
+			if !validFileName(string(s)) {
+				L.ArgError(1, "state field expected valid name.")
+			}
+			state = string(s)
+		default:
+			L.ArgError(1, "state field expected string value.")
+		}
+		var err error
+		if emulation, err = UnpackTabEmulation(L, v, emulation); err != nil {
+			L.ArgError(1, err.Error())
+		}
+		switch m := L.GetField(v, "metrics").(type) {
+		case *lua.LNilType:
+		case lua.LBool:
+			if m {
+				metricsExtra = "metrics"
+			}
+		case lua.LString:
+			if err := checkExtraKey(string(m)); err != nil {
+				L.ArgError(1, "metrics field "+err.Error())
+			}
+			metricsExtra = string(m)
+		default:
+			L.ArgError(1, "metrics field expected string or boolean value.")
+		}
+		switch h := L.GetField(v, "har").(type) {
+		case *lua.LNilType:
+		case lua.LBool:
+			if h {
+				har = NewHARRecorder(false)
+			}
+		case *lua.LTable:
+			har = NewHARRecorder(lua.LVAsBool(L.GetField(h, "body")))
+		default:
+			L.ArgError(1, "har field expected boolean or table value.")
+		}
+		failOnJSError = lua.LVAsBool(L.GetField(v, "failOnJSError"))
+		switch f := L.GetField(v, "failOnHTTPError").(type) {
+		case *lua.LNilType:
+		case lua.LBool:
+			failOnHTTPError = bool(f)
+		default:
+			L.ArgError(1, "failOnHTTPError field expected boolean value.")
+		}
+		switch n := L.GetField(v, "timeout").(type) {
+		case *lua.LNilType:
+		case lua.LNumber:
+			timeout = 0
+			if n > 0 {
+				timeout = time.Duration(float64(n) * float64(time.Millisecond))
+			}
+		default:
+			L.ArgError(1, "timeout field expected number value.")
 		}
-		recording = lua.LVAsBool(L.GetField(v, "recording"))
 	case *lua.LNilType:
 	default:
 		L.ArgError(1, "a nil, a string, or a table expected.")
 	}
 
 	t := AsyncRun(env, L, func() (*Tab, error) {
-		ctx, cancel := chromedp.NewContext(ctx)
+		var opts []chromedp.ContextOption
+		if env.BrowserContextID != "" {
+			b := chromedp.FromContext(ctx).Browser
+			id, err := target.CreateTarget("about:blank").WithBrowserContextID(env.BrowserContextID).Do(cdp.WithExecutor(ctx, b))
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, chromedp.WithTargetID(id))
+		}
+
+		ctx, cancel := chromedp.NewContext(ctx, opts...)
 		t := &Tab{
 			ctx:     ctx,
 			cancel:  cancel,
 			env:     env,
 			loading: NewLoadWaiter(),
+			network: NewNetworkTracker(),
+
+			id:       id,
+			viewport: viewport,
+
+			metricsExtra:  metricsExtra,
+			har:           har,
+			failOnJSError: failOnJSError,
+			timeout:       timeout,
 
-			id:     id,
-			width:  width,
-			height: height,
+			failOnHTTPError: failOnHTTPError,
 
-			dialogEvent:   NewEventHandler((*Tab).HandleDialog),
-			downloadEvent: NewEventHandler((*Tab).HandleEvent),
-			requestEvent:  NewEventHandler((*Tab).HandleEvent),
-			responseEvent: NewEventHandler((*Tab).HandleEvent),
+			dialogEvent:    NewEventHandler((*Tab).HandleDialog),
+			downloadEvent:  NewEventHandler((*Tab).HandleEvent),
+			requestEvent:   NewEventHandler((*Tab).HandleEvent),
+			responseEvent:  NewEventHandler((*Tab).HandleEvent),
+			consoleEvent:   NewEventHandler((*Tab).HandleEvent),
+			exceptionEvent: NewEventHandler((*Tab).HandleEvent),
+			router:         &Router{},
+			activity:       NewActivityLog(200),
+
+			frameContexts: make(map[target.ID]frameSession),
 		}
+		t.main = &Frame{tab: t, ctx: ctx, root: true, name: "$"}
 		err := t.RunInCallback(
 			browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllow).WithDownloadPath(env.storage.Dir).WithEventsEnabled(true),
-			chromedp.Emulate(device.Info{
-				UserAgent: userAgent,
-				Width:     t.width,
-				Height:    t.height,
-				Scale:     1,
-			}),
+			chromedp.Emulate(t.viewport),
 		)
+		if err == nil {
+			err = t.RunInCallback(emulation.Actions(TabEmulation{}, t.viewport.UserAgent)...)
+			t.emulation = emulation
+		}
+		if err == nil && t.networkEnabled() {
+			err = t.RunInCallback(network.Enable())
+		}
+		if err == nil && cookiejar != nil {
+			err = t.RunInCallback(network.SetCookies(cookiejar.ExportToBrowser()))
+		}
+		if err == nil && state != "" {
+			if s, ok := LoadTabState(filepath.Join(env.storage.StateDir, state+".json"), time.Now()); ok {
+				err = t.RunInCallback(chromedp.ActionFunc(func(ctx context.Context) error {
+					return t.restoreState(ctx, s)
+				}))
+			}
+		}
 		return t, err
 	})
 
-	if recording || env.EnableRecording {
-		t.recorder = NewRecorder(t.ctx, int(width), int(height))
+	t.listenEvents()
+
+	if recording == "" && env.ScreencastFormat != "" {
+		recording = env.ScreencastFormat
+	}
+	switch {
+	case recording == "gif" || (recording == "" && env.EnableRecording):
+		t.recorder = NewRecorder(t.ctx, int(viewport.Width), int(viewport.Height), recordingMaxFrames, recordingMaxBytes)
+	case recording != "":
+		t.screencast = NewScreencastRecorder(env.storage, id, recording, recordingSource, recordingMaxFrames, recordingMaxBytes)
+		t.RunInCallback(t.screencast.Start())
 	}
 
 	if url != "" {
-		t.Run(L, fmt.Sprintf("$:go(%q)", url), true, 0, chromedp.Navigate(url))
+		t.Run(L, fmt.Sprintf("$:go(%q)", url), true, 0, t.Navigate(navigateURL(url, ""), WaitLoad, nil))
 	}
 
 	return t
@@ -148,7 +335,10 @@ func (t *Tab) ToLua(L *lua.LState) *lua.LUserData {
 	lt := L.NewUserData()
 	lt.Value = t
 	L.SetMetatable(lt, L.GetTypeMetatable("tab"))
+	return lt
+}
 
+func (t *Tab) listenEvents() {
 	chromedp.ListenTarget(t.ctx, func(ev any) {
 		switch e := ev.(type) {
 		case *page.EventJavascriptDialogOpening:
@@ -171,7 +361,18 @@ func (t *Tab) ToLua(L *lua.LState) *lua.LUserData {
 			case browser.DownloadProgressStateCanceled:
 				t.env.storage.CancelDownload(e.GUID)
 			}
+		case *page.EventScreencastFrame:
+			if t.screencast != nil {
+				t.screencast.Push(e, func() {
+					t.RunInCallback(page.ScreencastFrameAck(e.SessionID))
+				})
+			}
 		case *network.EventRequestWillBeSent:
+			if t.har != nil {
+				t.har.OnRequest(e)
+			}
+			t.network.Start(e.RequestID, e.Type)
+			t.activity.Addf("request #%s: %s %s", e.RequestID, e.Request.Method, e.Request.URL+e.Request.URLFragment)
 			ev := t.env.BuildTable(func(L *lua.LState, ev *lua.LTable) {
 				L.SetField(ev, "id", lua.LString(e.RequestID.String()))
 				L.SetField(ev, "type", lua.LString(e.Type.String()))
@@ -184,8 +385,22 @@ func (t *Tab) ToLua(L *lua.LState) *lua.LUserData {
 			})
 			t.requestEvent.Invoke(t, ev)
 		case *network.EventLoadingFinished:
+			if t.har != nil {
+				t.har.OnFinished(e)
+			}
+			t.network.Finish(e.RequestID)
 			t.loading.Complete(e.RequestID)
+		case *network.EventLoadingFailed:
+			if t.har != nil {
+				t.har.OnFailed(e)
+			}
+			t.network.Finish(e.RequestID)
+			t.activity.Addf("failed #%s: %s", e.RequestID, e.ErrorText)
 		case *network.EventResponseReceived:
+			if t.har != nil {
+				t.har.OnResponse(e)
+			}
+			t.activity.Addf("response #%s: %d %s", e.RequestID, e.Response.Status, e.Response.URL)
 			ev := t.env.BuildTable(func(L *lua.LState, ev *lua.LTable) {
 				L.SetField(ev, "id", lua.LString(e.RequestID.String()))
 				L.SetField(ev, "type", lua.LString(e.Type.String()))
@@ -217,10 +432,59 @@ func (t *Tab) ToLua(L *lua.LState) *lua.LUserData {
 			})
 
 			t.responseEvent.Invoke(t, ev)
+		case *fetch.EventRequestPaused:
+			t.HandleRoute(e)
+		case *runtime.EventConsoleAPICalled:
+			message := formatConsoleArgs(e.Args)
+			t.activity.Addf("console.%s: %s", e.Type, message)
+
+			ev := t.env.BuildTable(func(L *lua.LState, ev *lua.LTable) {
+				L.SetField(ev, "type", lua.LString(e.Type.String()))
+				L.SetField(ev, "message", lua.LString(message))
+				args := L.NewTable()
+				for _, a := range e.Args {
+					args.Append(PackLValue(L, consoleArgValue(a)))
+				}
+				L.SetField(ev, "args", args)
+				if e.StackTrace != nil && len(e.StackTrace.CallFrames) > 0 {
+					f := e.StackTrace.CallFrames[0]
+					L.SetField(ev, "url", lua.LString(f.URL))
+					L.SetField(ev, "line", lua.LNumber(f.LineNumber+1))
+					L.SetField(ev, "column", lua.LNumber(f.ColumnNumber+1))
+				}
+			})
+			t.consoleEvent.Invoke(t, ev)
+		case *runtime.EventExceptionThrown:
+			message := formatException(e.ExceptionDetails)
+			t.activity.Addf("uncaught exception: %s", message)
+
+			ev := t.env.BuildTable(func(L *lua.LState, ev *lua.LTable) {
+				L.SetField(ev, "message", lua.LString(message))
+				L.SetField(ev, "url", lua.LString(e.ExceptionDetails.URL))
+				L.SetField(ev, "line", lua.LNumber(e.ExceptionDetails.LineNumber+1))
+				L.SetField(ev, "column", lua.LNumber(e.ExceptionDetails.ColumnNumber+1))
+			})
+			t.exceptionEvent.Invoke(t, ev)
+
+			if t.failOnJSError {
+				select {
+				case t.env.errch <- fmt.Errorf("tab#%d: uncaught exception: %s", t.id, message):
+				default:
+				}
+			}
+		case *page.EventFrameNavigated:
+			if e.Frame.ParentID == "" {
+				t.activity.Addf("navigated: %s", e.Frame.URL)
+				if id, ok := t.seeds.Pop(e.Frame.SecurityOrigin); ok {
+					t.wg.Add(1)
+					go func() {
+						t.RunInCallback(page.RemoveScriptToEvaluateOnNewDocument(id))
+						t.wg.Done()
+					}()
+				}
+			}
 		}
 	})
-
-	return lt
 }
 
 func captureScreenshotForRecording(buf *[]byte) chromedp.ActionFunc {
@@ -246,7 +510,7 @@ func (t *Tab) RecordOnce(L *lua.LState, taskName string) {
 			false,
 			0,
 			captureScreenshotForRecording(&buf),
-			t.recorder.Record(where, &buf),
+			t.recorder.Record(where, &buf, nil),
 		)
 	}
 }
@@ -257,64 +521,95 @@ func (t *Tab) RunInCallback(actions ...chromedp.Action) error {
 }
 
 func (t *Tab) Run(L *lua.LState, taskName string, capture bool, timeout time.Duration, action ...chromedp.Action) {
+	t.run(L, t.ctx, taskName, capture, timeout, nil, action...)
+}
+
+// run runs the actions in ctx, that is the context of the tab or a frame in the tab.
+func (t *Tab) run(L *lua.LState, ctx context.Context, taskName string, capture bool, timeout time.Duration, target *RecordTarget, action ...chromedp.Action) {
 	where := L.Where(1)
 	t.env.StartTask(where, taskName)
+	if t.screencast != nil {
+		t.screencast.SetWhere(where)
+	}
 
 	AsyncRun(t.env, L, func() (struct{}, error) {
-		ctx := t.ctx
+		if capture && t.recorder != nil {
+			var buf []byte
+			record := []chromedp.Action{
+				captureScreenshotForRecording(&buf),
+				t.recorder.Record(where, &buf, target),
+			}
+
+			if ctx == t.ctx {
+				action = append(action, record...)
+			} else {
+				// A frame that has own target can't take a screenshot of the whole tab.
+				action = append(action, chromedp.ActionFunc(func(fctx context.Context) error {
+					tctx := t.ctx
+					if deadline, ok := fctx.Deadline(); ok {
+						var cancel context.CancelFunc
+						tctx, cancel = context.WithDeadline(tctx, deadline)
+						defer cancel()
+					}
+					return chromedp.Run(tctx, record...)
+				}))
+			}
+		}
 		if timeout > 0 {
 			var cancel context.CancelFunc
 			ctx, cancel = context.WithTimeout(ctx, timeout)
 			defer cancel()
 		}
-		if capture && t.recorder != nil {
-			var buf []byte
-
-			action = append(
-				action,
-				captureScreenshotForRecording(&buf),
-				t.recorder.Record(where, &buf),
-			)
-		}
 		return struct{}{}, chromedp.Run(ctx, action...)
 	})
 }
 
-func (t *Tab) RunSelector(L *lua.LState, taskName string, action ...chromedp.Action) {
-	t.env.StartTask(L.Where(1), taskName)
-
-	AsyncRun(t.env, L, func() (struct{}, error) {
-		ctx, cancel := context.WithTimeout(t.ctx, time.Second)
-		defer cancel()
-
-		err := chromedp.Run(ctx, action...)
-		if errors.Is(err, context.DeadlineExceeded) {
-			return struct{}{}, errors.New("no such element")
+// OptTimeout gets a timeout in milliseconds from the n-th argument.
+// It returns the default timeout of the tab if the argument is omitted, or 0 that means no limit if the argument is 0 or negative.
+func (t *Tab) OptTimeout(L *lua.LState, n int) time.Duration {
+	switch v := L.Get(n).(type) {
+	case *lua.LNilType:
+		return t.timeout
+	case lua.LNumber:
+		if v <= 0 {
+			return 0
 		}
-		return struct{}{}, err
-	})
+		return time.Duration(float64(v) * float64(time.Millisecond))
+	default:
+		L.ArgError(n, "number expected.")
+		return 0
+	}
 }
 
-func (t *Tab) Save(name, ext string, data []byte) error {
+func (t *Tab) Save(name, ext string, data []byte) (string, error) {
 	return t.env.storage.Save(name, ext, data)
 }
 
-func (t *Tab) Go(L *lua.LState) {
+func (t *Tab) Go(L *lua.LState) int {
 	url := L.CheckString(2)
+	until, timeout, referer := checkGoOptions(L, 3)
 
-	t.Run(L, fmt.Sprintf("$:go(%q)", url), true, 0, chromedp.Navigate(url))
+	var res *NavigationResponse
+	t.Run(L, fmt.Sprintf("$:go(%q)", url), true, timeout, t.Navigate(navigateURL(url, referer), until, &res))
+
+	resp := PackNavigationResponse(L, res)
+	if resp == lua.LNil {
+		resp = L.NewTable()
+	}
+	L.Push(resp)
+	return 1
 }
 
 func (t *Tab) Forward(L *lua.LState) {
-	t.Run(L, "$:forward()", true, 0, chromedp.NavigateForward())
+	t.Run(L, "$:forward()", true, 0, t.Navigate(navigateHistory(1), WaitLoad, nil))
 }
 
 func (t *Tab) Back(L *lua.LState) {
-	t.Run(L, "$:back()", true, 0, chromedp.NavigateBack())
+	t.Run(L, "$:back()", true, 0, t.Navigate(navigateHistory(-1), WaitLoad, nil))
 }
 
 func (t *Tab) Reload(L *lua.LState) {
-	t.Run(L, "$:reload()", true, 0, chromedp.Reload())
+	t.Run(L, "$:reload()", true, 0, t.Navigate(reloadPage, WaitLoad, nil))
 }
 
 func (t *Tab) Close() error {
@@ -323,16 +618,57 @@ func (t *Tab) Close() error {
 
 		t.env.unregisterTab(t)
 
+		if t.metricsExtra != "" {
+			ctx, cancel := context.WithTimeout(t.ctx, 5*time.Second)
+			var m map[string]any
+			err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+				m, err = t.collectMetrics(ctx)
+				return err
+			}))
+			cancel()
+			if err == nil {
+				t.publishMetrics(t.metricsExtra, m)
+			}
+		}
+
+		if t.har != nil && !t.harSaved {
+			ctx, cancel := context.WithTimeout(t.ctx, 5*time.Second)
+			chromedp.Run(ctx, chromedp.ActionFunc(t.har.FetchBodies))
+			cancel()
+			if data, err := t.har.Bytes(); err == nil {
+				t.Save(fmt.Sprintf("network%d", t.id), ".har", data)
+			}
+		}
+
+		if t.screencast != nil {
+			ctx, cancel := context.WithTimeout(t.ctx, 5*time.Second)
+			chromedp.Run(ctx, page.StopScreencast())
+			cancel()
+			t.screencast.Close()
+		}
+
+		t.frameContextsMu.Lock()
+		for _, s := range t.frameContexts {
+			s.cancel()
+		}
+		t.frameContexts = nil
+		t.frameContextsMu.Unlock()
+
 		t.cancel()
 
 		if t.recorder != nil {
 			t.env.saveRecord(t.id, t.recorder)
 		}
+		if t.screencast != nil {
+			t.env.saveScreencast(t.screencast)
+		}
 
 		t.dialogEvent.Close()
 		t.downloadEvent.Close()
 		t.requestEvent.Close()
 		t.responseEvent.Close()
+		t.consoleEvent.Close()
+		t.exceptionEvent.Close()
 
 		return struct{}{}, nil
 	})
@@ -346,48 +682,31 @@ func (t *Tab) LClose(L *lua.LState) {
 	t.Close()
 }
 
-func (t *Tab) Screenshot(L *lua.LState) {
-	name := L.ToString(2)
+func (t *Tab) Screenshot(L *lua.LState) int {
+	name, opts := checkScreenshotArgs(L)
 
 	var buf []byte
+	capture := chromedp.CaptureScreenshot(&buf)
+	ext := ".png"
+	if opts != nil {
+		capture = opts.Capture(nil, &buf)
+		ext = opts.Ext()
+	}
+
+	var path string
 	t.Run(
 		L,
 		fmt.Sprintf("$:screenshot(%v)", name),
 		false,
 		0,
-		chromedp.CaptureScreenshot(&buf),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			return t.Save(name, ".png", buf)
+		capture,
+		chromedp.ActionFunc(func(ctx context.Context) (err error) {
+			path, err = t.Save(name, ext, buf)
+			return err
 		}),
 	)
-}
-
-func (t *Tab) Wait(L *lua.LState) {
-	query := L.CheckString(2)
-	timeout := time.Duration(float64(L.OptNumber(3, 0)) * float64(time.Millisecond))
-
-	t.Run(L, fmt.Sprintf("$:wait(%q)", query), true, timeout, chromedp.WaitReady(query, chromedp.ByQuery))
-}
-
-func (t *Tab) WaitVisible(L *lua.LState) {
-	query := L.CheckString(2)
-	timeout := time.Duration(float64(L.OptNumber(3, 0)) * float64(time.Millisecond))
-
-	t.Run(L, fmt.Sprintf("$:waitVisible(%q)", query), true, timeout, chromedp.WaitVisible(query, chromedp.ByQuery))
-}
-
-func (t *Tab) WaitXPath(L *lua.LState) {
-	query := L.CheckString(2)
-	timeout := time.Duration(float64(L.OptNumber(3, 0)) * float64(time.Millisecond))
-
-	t.Run(L, fmt.Sprintf("$:waitXPath(%q)", query), true, timeout, chromedp.WaitReady(query, chromedp.BySearch))
-}
-
-func (t *Tab) WaitXPathVisible(L *lua.LState) {
-	query := L.CheckString(2)
-	timeout := time.Duration(float64(L.OptNumber(3, 0)) * float64(time.Millisecond))
-
-	t.Run(L, fmt.Sprintf("$:waitXPathVisible(%q)", query), true, timeout, chromedp.WaitVisible(query, chromedp.BySearch))
+	L.Push(lua.LString(path))
+	return 1
 }
 
 func (t *Tab) WaitEvent(L *lua.LState, taskName string, h *EventHandler) int {
@@ -431,6 +750,14 @@ func (t *Tab) WaitResponse(L *lua.LState) int {
 	return t.WaitEvent(L, "t:waitResponse()", t.responseEvent)
 }
 
+func (t *Tab) WaitConsole(L *lua.LState) int {
+	return t.WaitEvent(L, "t:waitConsole()", t.consoleEvent)
+}
+
+func (t *Tab) WaitException(L *lua.LState) int {
+	return t.WaitEvent(L, "t:waitException()", t.exceptionEvent)
+}
+
 func (t *Tab) GetDialogs(L *lua.LState) int {
 	L.Push(t.dialogEvent.Status(L))
 	return 1
@@ -451,6 +778,16 @@ func (t *Tab) GetResponse(L *lua.LState) int {
 	return 1
 }
 
+func (t *Tab) GetConsole(L *lua.LState) int {
+	L.Push(t.consoleEvent.Status(L))
+	return 1
+}
+
+func (t *Tab) GetException(L *lua.LState) int {
+	L.Push(t.exceptionEvent.Status(L))
+	return 1
+}
+
 func (t *Tab) HandleEvent(f *lua.LFunction, ev *lua.LTable) {
 	if f != nil {
 		t.wg.Add(1)
@@ -480,6 +817,43 @@ func (t *Tab) HandleDialog(f *lua.LFunction, ev *lua.LTable) {
 	}()
 }
 
+func (t *Tab) HandleRoute(e *fetch.EventRequestPaused) {
+	t.wg.Add(1)
+	go func() {
+		f := t.router.Match(e.Request.URL + e.Request.URLFragment)
+		if f == nil {
+			t.RunInCallback(fetch.ContinueRequest(e.RequestID))
+		} else {
+			ev := t.env.BuildTable(func(L *lua.LState, ev *lua.LTable) {
+				L.SetField(ev, "id", lua.LString(e.NetworkID.String()))
+				L.SetField(ev, "type", lua.LString(e.ResourceType.String()))
+				L.SetField(ev, "url", lua.LString(e.Request.URL+e.Request.URLFragment))
+				L.SetField(ev, "method", lua.LString(e.Request.Method))
+				L.SetField(ev, "headers", PackLValue(L, e.Request.Headers))
+				if e.Request.HasPostData {
+					L.SetField(ev, "body", lua.LString(e.Request.PostData))
+				}
+			})
+
+			result := t.env.CallEventHandler(f, ev, 1)
+
+			t.env.Lock()
+			action, err := buildRouteAction(t.env.lua, e.RequestID, result[0])
+			t.env.Unlock()
+
+			if err != nil {
+				select {
+				case t.env.errch <- fmt.Errorf("tab#%d: invalid return value of route handler: %s", t.id, err):
+				default:
+				}
+			}
+
+			t.RunInCallback(action)
+		}
+		t.wg.Done()
+	}()
+}
+
 func (t *Tab) OnDialog(L *lua.LState) {
 	t.dialogEvent.SetFunc(L.OptFunction(2, nil))
 }
@@ -488,11 +862,25 @@ func (t *Tab) OnDownload(L *lua.LState) {
 	t.downloadEvent.SetFunc(L.OptFunction(2, nil))
 }
 
+func (t *Tab) OnConsole(L *lua.LState) {
+	t.consoleEvent.SetFunc(L.OptFunction(2, nil))
+}
+
+func (t *Tab) OnException(L *lua.LState) {
+	t.exceptionEvent.SetFunc(L.OptFunction(2, nil))
+}
+
+// networkEnabled reports whether the tab needs network events.
+func (t *Tab) networkEnabled() bool {
+	return t.requestEvent.IsFuncSet() || t.responseEvent.IsFuncSet() || t.throttling != nil || t.har != nil || t.networkIdle || t.env.EnableEvidence
+}
+
 func (t *Tab) updateNetworkConfig(L *lua.LState, taskName string) {
-	if t.requestEvent.IsFuncSet() || t.responseEvent.IsFuncSet() {
-		t.Run(L, taskName, false, 0, network.Enable())
+	if t.networkEnabled() {
+		t.Run(L, taskName, false, 0, network.Enable(), t.throttling.Action())
 	} else {
-		t.Run(L, taskName, false, 0, network.Disable())
+		t.Run(L, taskName, false, 0, t.throttling.Action(), network.Disable())
+		t.network.Reset()
 	}
 }
 
@@ -506,13 +894,44 @@ func (t *Tab) OnResponse(L *lua.LState) {
 	t.updateNetworkConfig(L, "$:onResponse()")
 }
 
-func (t *Tab) Eval(L *lua.LState) int {
-	script := L.CheckString(2)
+func (t *Tab) Throttle(L *lua.LState) {
+	c, err := UnpackNetworkConditions(L, L.Get(2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	t.throttling = c
+	t.updateNetworkConfig(L, "$:throttle()")
+}
 
-	var res any
-	t.Run(L, fmt.Sprintf("$:eval([[ %s ]])", script), true, 0, chromedp.Evaluate(script, &res))
-	L.Push(PackLValue(L, res))
-	return 1
+func (t *Tab) SaveHAR(L *lua.LState) {
+	name := L.OptString(2, "")
+	if t.har == nil {
+		L.RaiseError("HAR recording is not enabled. please use tab.new{har=true}.")
+	}
+
+	t.Run(L, fmt.Sprintf("$:saveHAR(%q)", name), false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := t.har.FetchBodies(ctx); err != nil {
+			return err
+		}
+		data, err := t.har.Bytes()
+		if err != nil {
+			return err
+		}
+		_, err = t.Save(name, ".har", data)
+		return err
+	}))
+	t.harSaved = true
+}
+
+func (t *Tab) Route(L *lua.LState) {
+	pattern := L.CheckString(2)
+	t.router.Set(pattern, L.OptFunction(3, nil))
+
+	var action chromedp.Action = fetch.Disable()
+	if ps := t.router.Patterns(); len(ps) > 0 {
+		action = fetch.Enable().WithPatterns(ps)
+	}
+	t.Run(L, fmt.Sprintf("$:route(%q)", pattern), false, 0, action)
 }
 
 func (t *Tab) GetURL(L *lua.LState) int {
@@ -529,16 +948,239 @@ func (t *Tab) GetTitle(L *lua.LState) int {
 	return 1
 }
 
+func (t *Tab) GetCookies(L *lua.LState) int {
+	var cs []*network.Cookie
+	t.Run(L, "$.cookies", false, 0, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+		cs, err = network.GetCookies().Do(ctx)
+		return err
+	}))
+
+	tbl := L.NewTable()
+	for _, c := range cs {
+		tbl.Append(PackCookie(L, CookieFromBrowser(c)))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+func (t *Tab) SetCookie(L *lua.LState) {
+	tbl := L.CheckTable(2)
+	c := UnpackCookie(L, tbl)
+	url := lua.LVAsString(L.GetField(tbl, "url"))
+	if c.Name == "" {
+		L.ArgError(2, "name field is required.")
+	}
+
+	t.Run(L, fmt.Sprintf("$:setCookie(%q)", c.Name), false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		if url == "" && c.Domain == "" {
+			if err := chromedp.Location(&url).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return network.SetCookies([]*network.CookieParam{CookieToBrowser(c, url)}).Do(ctx)
+	}))
+}
+
+// ClearCookies deletes the cookies for the current URL, rather than all cookies in the browser.
+func (t *Tab) ClearCookies(L *lua.LState) {
+	t.Run(L, "$:clearCookies()", false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		cs, err := network.GetCookies().Do(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range cs {
+			if err := network.DeleteCookies(c.Name).WithDomain(c.Domain).WithPath(c.Path).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func (t *Tab) CookieJar(L *lua.LState) int {
+	var cs []*network.Cookie
+	t.Run(L, "$:cookiejar()", false, 0, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+		cs, err = network.GetCookies().Do(ctx)
+		return err
+	}))
+
+	jar, err := t.env.NewCookieJar()
+	if err != nil {
+		L.RaiseError("failed to prepare session: %s", err)
+	}
+	jar.ImportFromBrowser(cs)
+
+	L.Push(jar.ToLua(L))
+	return 1
+}
+
+func (t *Tab) UseCookies(L *lua.LState) {
+	jar := CheckCookieJar(L, 2)
+	t.Run(L, "$:useCookies()", false, 0, network.SetCookies(jar.ExportToBrowser()))
+}
+
+func (t *Tab) GetLocalStorage(L *lua.LState) int {
+	t.env.Yield()
+	L.Push(NewWebStorage(t, true).ToLua(L))
+	return 1
+}
+
+func (t *Tab) GetSessionStorage(L *lua.LState) int {
+	t.env.Yield()
+	L.Push(NewWebStorage(t, false).ToLua(L))
+	return 1
+}
+
+func (t *Tab) IndexedDB(L *lua.LState) int {
+	var dump map[string]map[string][]map[string]any
+	t.Run(L, "$:indexedDB()", false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		origin, err := pageOrigin(ctx)
+		if err != nil {
+			return err
+		}
+		dump, err = dumpIndexedDB(ctx, origin)
+		return err
+	}))
+	L.Push(PackLValue(L, dump))
+	return 1
+}
+
+func (t *Tab) Emulate(L *lua.LState) {
+	e, err := UnpackTabEmulation(L, L.CheckTable(2), t.emulation)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+
+	t.Run(L, "$:emulate()", true, 0, e.Actions(t.emulation, t.viewport.UserAgent)...)
+	t.emulation = e
+}
+
+func (t *Tab) SaveState(L *lua.LState) {
+	name := L.CheckString(2)
+	path, err := t.statePath(name)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	ttl := DefaultStateTTL
+	if n, ok := L.Get(3).(lua.LNumber); ok {
+		ttl = time.Duration(float64(n) * float64(time.Millisecond))
+	}
+
+	t.Run(L, fmt.Sprintf("$:saveState(%q)", name), false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		s, err := captureState(ctx, ttl)
+		if err != nil {
+			return err
+		}
+		return s.Save(path)
+	}))
+}
+
+func (t *Tab) LoadState(L *lua.LState) int {
+	name := L.CheckString(2)
+	path, err := t.statePath(name)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+
+	loaded := false
+	t.Run(L, fmt.Sprintf("$:loadState(%q)", name), false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		s, ok := LoadTabState(path, time.Now())
+		if !ok {
+			return nil
+		}
+		loaded = true
+		return t.restoreState(ctx, s)
+	}))
+	L.Push(lua.LBool(loaded))
+	return 1
+}
+
+func (t *Tab) GetMetrics(L *lua.LState) int {
+	var m map[string]any
+	t.Run(L, "$.metrics", false, 0, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+		m, err = t.collectMetrics(ctx)
+		return err
+	}))
+	L.Push(PackLValue(L, m))
+	return 1
+}
+
+func (t *Tab) Performance(L *lua.LState) int {
+	key := L.OptString(2, "")
+	if err := checkExtraKey(key); err != nil {
+		L.ArgError(2, err.Error())
+	}
+
+	var m map[string]any
+	t.Run(L, "$:performance()", false, 0, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+		m, err = t.collectMetrics(ctx)
+		return err
+	}))
+	if key != "" {
+		t.publishMetrics(key, m)
+	}
+	L.Push(PackLValue(L, m))
+	return 1
+}
+
 func (t *Tab) GetViewport(L *lua.LState) int {
 	t.env.Yield()
 
 	v := L.NewTable()
-	L.SetField(v, "width", lua.LNumber(t.width))
-	L.SetField(v, "height", lua.LNumber(t.height))
+	L.SetField(v, "width", lua.LNumber(t.viewport.Width))
+	L.SetField(v, "height", lua.LNumber(t.viewport.Height))
 	L.Push(v)
 	return 1
 }
 
+func (t *Tab) SetViewport(L *lua.LState) {
+	tbl := L.CheckTable(2)
+
+	viewport := t.viewport
+	if w, ok := L.GetField(tbl, "width").(lua.LNumber); ok {
+		viewport.Width = int64(w)
+	}
+	if h, ok := L.GetField(tbl, "height").(lua.LNumber); ok {
+		viewport.Height = int64(h)
+	}
+	if s, ok := L.GetField(tbl, "scale").(lua.LNumber); ok {
+		viewport.Scale = float64(s)
+	}
+	if m, ok := L.GetField(tbl, "mobile").(lua.LBool); ok {
+		viewport.Mobile = bool(m)
+		viewport.Touch = bool(m)
+	}
+	if l, ok := L.GetField(tbl, "landscape").(lua.LBool); ok {
+		viewport.Landscape = bool(l)
+	}
+	if viewport.Width <= 0 || viewport.Height <= 0 || viewport.Scale <= 0 {
+		L.ArgError(2, "width, height, and scale should be greater than 0.")
+	}
+
+	t.Run(
+		L,
+		fmt.Sprintf("$:setViewport{width=%d, height=%d}", viewport.Width, viewport.Height),
+		true,
+		0,
+		chromedp.Emulate(viewport),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			if t.emulation.Locale != "" {
+				// chromedp.Emulate resets Accept-Language.
+				if err := userAgentOverride(viewport.UserAgent, t.emulation.Locale).Do(ctx); err != nil {
+					return err
+				}
+			}
+			if t.recorder != nil {
+				t.recorder.Resize(int(viewport.Width), int(viewport.Height))
+			}
+			return nil
+		}),
+	)
+
+	// Update it after the action with the GIL, because the action runs without the GIL.
+	t.viewport = viewport
+}
+
 func RegisterTabType(ctx context.Context, env *Environment) {
 	fn := func(f func(*Tab, *lua.LState)) *lua.LFunction {
 		return env.NewFunction(func(L *lua.LState) int {
@@ -555,51 +1197,112 @@ func RegisterTabType(ctx context.Context, env *Environment) {
 	}
 
 	methods := map[string]*lua.LFunction{
-		"go":               fn((*Tab).Go),
 		"forward":          fn((*Tab).Forward),
 		"back":             fn((*Tab).Back),
 		"reload":           fn((*Tab).Reload),
 		"close":            fn((*Tab).LClose),
-		"screenshot":       fn((*Tab).Screenshot),
-		"wait":             fn((*Tab).Wait),
-		"waitXPath":        fn((*Tab).WaitXPath),
-		"waitVisible":      fn((*Tab).WaitVisible),
-		"waitXPathVisible": fn((*Tab).WaitXPathVisible),
+		"screenshot":       fret((*Tab).Screenshot),
+		"pdf":              fret((*Tab).PDF),
+		"mhtml":            fret((*Tab).MHTML),
+		"wait":             fn(func(t *Tab, L *lua.LState) { t.main.Wait(L) }),
+		"waitXPath":        fn(func(t *Tab, L *lua.LState) { t.main.WaitXPath(L) }),
+		"waitVisible":      fn(func(t *Tab, L *lua.LState) { t.main.WaitVisible(L) }),
+		"waitXPathVisible": fn(func(t *Tab, L *lua.LState) { t.main.WaitXPathVisible(L) }),
+		"waitFor":          fret(func(t *Tab, L *lua.LState) int { return t.main.WaitFor(L) }),
+		"waitText":         fn(func(t *Tab, L *lua.LState) { t.main.WaitText(L) }),
+		"waitHidden":       fn(func(t *Tab, L *lua.LState) { t.main.WaitHidden(L) }),
+		"waitGone":         fn(func(t *Tab, L *lua.LState) { t.main.WaitGone(L) }),
+		"waitURL":          fn((*Tab).WaitURL),
+		"waitNetworkIdle":  fn((*Tab).WaitNetworkIdle),
 		"waitDialog":       fret((*Tab).WaitDialog),
 		"waitDownload":     fret((*Tab).WaitDownload),
 		"waitRequest":      fret((*Tab).WaitRequest),
 		"waitResponse":     fret((*Tab).WaitResponse),
+		"waitConsole":      fret((*Tab).WaitConsole),
+		"waitException":    fret((*Tab).WaitException),
 		"onDialog":         fn((*Tab).OnDialog),
 		"onDownload":       fn((*Tab).OnDownload),
 		"onRequest":        fn((*Tab).OnRequest),
 		"onResponse":       fn((*Tab).OnResponse),
+		"onConsole":        fn((*Tab).OnConsole),
+		"onException":      fn((*Tab).OnException),
+		"route":            fn((*Tab).Route),
+		"throttle":         fn((*Tab).Throttle),
+		"saveHAR":          fn((*Tab).SaveHAR),
+		"setCookie":        fn((*Tab).SetCookie),
+		"clearCookies":     fn((*Tab).ClearCookies),
+		"useCookies":       fn((*Tab).UseCookies),
+		"cookiejar":        fret((*Tab).CookieJar),
+		"indexedDB":        fret((*Tab).IndexedDB),
+		"performance":      fret((*Tab).Performance),
+		"setViewport":      fn((*Tab).SetViewport),
+		"emulate":          fn((*Tab).Emulate),
+		"saveState":        fn((*Tab).SaveState),
+		"loadState":        fret((*Tab).LoadState),
+		"frame":            fret(func(t *Tab, L *lua.LState) int { return t.main.Frame(L) }),
 		"all": env.NewFunction(func(L *lua.LState) int {
 			t := CheckTab(L)
 			query := L.CheckString(2)
-			L.Push(NewElementsTable(L, t, query))
+			L.Push(NewElementsTable(L, t.main, query))
 			return 1
 		}),
 		"xpath": env.NewFunction(func(L *lua.LState) int {
 			t := CheckTab(L)
 			query := L.CheckString(2)
-			L.Push(NewElementsTableByXPath(L, t, query))
+			L.Push(NewElementsTableByXPath(L, t.main, query))
 			return 1
 		}),
 		"eval": env.NewFunction(func(L *lua.LState) int {
-			return CheckTab(L).Eval(L)
+			return CheckTab(L).main.Eval(L)
 		}),
 	}
 
 	getters := map[string]func(*Tab, *lua.LState) int{
-		"url":       (*Tab).GetURL,
-		"title":     (*Tab).GetTitle,
-		"viewport":  (*Tab).GetViewport,
-		"dialogs":   (*Tab).GetDialogs,
-		"downloads": (*Tab).GetDownload,
-		"requests":  (*Tab).GetRequest,
-		"responses": (*Tab).GetResponse,
+		"url":            (*Tab).GetURL,
+		"title":          (*Tab).GetTitle,
+		"viewport":       (*Tab).GetViewport,
+		"metrics":        (*Tab).GetMetrics,
+		"cookies":        (*Tab).GetCookies,
+		"localStorage":   (*Tab).GetLocalStorage,
+		"sessionStorage": (*Tab).GetSessionStorage,
+		"dialogs":        (*Tab).GetDialogs,
+		"downloads":      (*Tab).GetDownload,
+		"requests":       (*Tab).GetRequest,
+		"responses":      (*Tab).GetResponse,
+		"consoles":       (*Tab).GetConsole,
+		"exceptions":     (*Tab).GetException,
+		"frames":         (*Tab).GetFrames,
 	}
 
+	// The response of tab:go() also has the methods of the tab, to keep method chaining like t:go(url):wait(query).
+	methods["go"] = env.NewFunction(func(L *lua.LState) int {
+		tab := L.Get(1)
+		CheckTab(L).Go(L)
+
+		resp := L.Get(-1).(*lua.LTable)
+		meta := L.NewTable()
+		L.SetField(meta, "__index", L.NewFunction(func(L *lua.LState) int {
+			f, ok := methods[L.CheckString(2)]
+			if !ok {
+				return 0
+			}
+			L.Push(L.NewFunction(func(L *lua.LState) int {
+				if L.GetTop() == 0 {
+					L.Push(tab)
+				} else {
+					L.Replace(1, tab)
+				}
+				L.Insert(f, 1)
+				L.Call(L.GetTop()-1, lua.MultRet)
+				return L.GetTop()
+			}))
+			return 1
+		}))
+		L.SetMetatable(resp, meta)
+
+		return 1
+	})
+
 	count := 0
 
 	env.RegisterNewType("tab", map[string]lua.LGFunction{
@@ -611,7 +1314,7 @@ func RegisterTabType(ctx context.Context, env *Environment) {
 			return 1
 		},
 		"__call": func(L *lua.LState) int {
-			L.Push(NewElement(L, CheckTab(L), L.CheckString(2)).ToLua(L))
+			L.Push(NewElement(L, CheckTab(L).main, L.CheckString(2)).ToLua(L))
 			return 1
 		},
 		"__index": func(L *lua.LState) int {
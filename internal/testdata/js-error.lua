@@ -0,0 +1,7 @@
+t = tab.new({url=TEST.url("/console"), failOnJSError=true})
+
+t("#throw"):click()
+
+t:waitConsole()
+
+t:close()
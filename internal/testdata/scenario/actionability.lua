@@ -0,0 +1,28 @@
+t = tab.new({url=TEST.url("/actionability"), timeout=5*time.second})
+
+-- wait until the button gets enabled.
+t("#late"):click()
+assert.eq(t("#late").text, "clicked")
+
+-- wait until the input gets visible.
+t("#hidden"):sendKeys(" world")
+assert.eq(t("#hidden").value, "hello world")
+
+-- wait until the element appears, longer than 1 second.
+assert.eq(t("#appear").text, "hello")
+
+ok, err = pcall(t("#never").click, t("#never"))
+assert.eq(ok, false)
+assert.eq(err:find("element is not enabled", 1, true) ~= nil, true)
+
+t2 = tab.new({url=TEST.url("/actionability"), timeout=200*time.millisecond})
+
+ok, err = pcall(t2, "#no-such-element")
+assert.eq(ok, false)
+assert.eq(err:find("no such element", 1, true) ~= nil, true)
+
+ok = pcall(t2.wait, t2, "#appear")
+assert.eq(ok, false)
+
+ok = pcall(tab.new, {timeout="1s"})
+assert.eq(ok, false)
@@ -0,0 +1,15 @@
+t = tab.new(TEST.url())
+
+local path = t:pdf("page", {landscape=true, paper="A4", printBackground=true})
+assert.eq(artifact.list, {"page.pdf"})
+assert.eq(path:sub(-#"page.pdf"), "page.pdf")
+assert.eq(artifact.open("page.pdf", "rb"):read(5), "%PDF-")
+
+t:pdf()
+assert.eq(artifact.list, {"page.pdf", "000001.pdf"})
+
+assert.eq(pcall(t.pdf, t, "bad", {paper="b5"}), false)
+
+t:mhtml("page")
+assert.eq(artifact.list, {"page.pdf", "000001.pdf", "page.mhtml"})
+assert.ne(artifact.open("page.mhtml"):read("*a"):find("MIME-Version", 1, true), nil)
@@ -0,0 +1,33 @@
+t = tab.new(TEST.url("/console"))
+
+_, c = t:waitConsole(1000)
+assert.eq(c.type, "log")
+assert.eq(c.message, "hello 42")
+assert.eq(c.args, {"hello", 42})
+assert.eq(c.url, TEST.url("/console"))
+
+called = nil
+t:onConsole(function(c)
+    called = c
+end)
+t("button"):click()
+_, c = t:waitConsole(1000)
+assert.eq(c.type, "warning")
+assert.eq(c.message, "clicked Object")
+assert.eq(called.message, "clicked Object")
+assert.eq(#t.consoles, 2)
+
+
+called = nil
+t:onException(function(e)
+    called = e
+end)
+t("#throw"):click()
+_, e = t:waitException(1000)
+assert.eq(e.message:sub(1, 12), "Error: boom\n")
+assert.eq(e.url, TEST.url("/console"))
+assert.eq(called.message, e.message)
+assert.eq(#t.exceptions, 1)
+
+t:close()
+
@@ -0,0 +1,23 @@
+t = tab.new({ device="iPhone 13", url=TEST.url() })
+assert.eq(t.viewport, { width=390, height=844 })
+assert.eq(t:eval("[window.innerWidth, window.innerHeight]"), {390, 844})
+assert.eq(t:eval("window.devicePixelRatio"), 3)
+assert.eq(t:eval("navigator.userAgent"):find("iPhone") ~= nil, true)
+assert.eq(t:eval("'ontouchstart' in window"), true)
+
+t:setViewport({ width=1024, height=768, scale=1, mobile=false })
+assert.eq(t.viewport, { width=1024, height=768 })
+assert.eq(t:eval("[window.innerWidth, window.innerHeight]"), {1024, 768})
+assert.eq(t:eval("window.devicePixelRatio"), 1)
+
+t:setViewport({ height=600, landscape=true })
+assert.eq(t.viewport, { width=1024, height=600 })
+assert.eq(t:eval("screen.orientation.type"), "landscape-primary")
+t:close()
+
+t = tab.new({ device="pixel 5", width=400 })
+assert.eq(t.viewport, { width=400, height=851 })
+t:close()
+
+assert.eq(pcall(tab.new, { device="no such device" }), false)
+assert.eq(pcall(function() tab.new():setViewport({ width=0 }) end), false)
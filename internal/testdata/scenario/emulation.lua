@@ -0,0 +1,37 @@
+t = tab.new({
+    url           = TEST.url("/geolocation"),
+    geolocation   = {latitude=35.5, longitude=139.25},
+    timezone      = "Asia/Tokyo",
+    locale        = "ja-JP",
+    colorScheme   = "dark",
+    reducedMotion = "reduce",
+})
+
+assert.eq(t("#position").text, "35.5,139.25")
+assert.eq(t:eval("Intl.DateTimeFormat().resolvedOptions().timeZone"), "Asia/Tokyo")
+assert.eq(t:eval("new Date(0).getTimezoneOffset()"), -540)
+assert.eq(t:eval("navigator.language"), "ja-JP")
+assert.eq(t:eval("matchMedia('(prefers-color-scheme: dark)').matches"), true)
+assert.eq(t:eval("matchMedia('(prefers-reduced-motion: reduce)').matches"), true)
+
+t:go(TEST.url("/accept-language"))
+assert.eq(t("body").text:sub(1, 5), "ja-JP")
+
+
+t:emulate({timezone="UTC", colorScheme="light"})
+assert.eq(t:eval("Intl.DateTimeFormat().resolvedOptions().timeZone"), "UTC")
+assert.eq(t:eval("matchMedia('(prefers-color-scheme: light)').matches"), true)
+assert.eq(t:eval("matchMedia('(prefers-reduced-motion: reduce)').matches"), true)
+
+t:emulate({geolocation={latitude=-33.75, longitude=151}})
+t:go(TEST.url("/geolocation"))
+assert.eq(t("#position").text, "-33.75,151")
+
+t:emulate({locale=false, reducedMotion=false})
+assert.eq(t:eval("matchMedia('(prefers-reduced-motion: reduce)').matches"), false)
+t:go(TEST.url("/accept-language"))
+assert.ne(t("body").text:sub(1, 5), "ja-JP")
+
+
+assert.eq(pcall(t.emulate, t, {colorScheme="blue"}), false)
+assert.eq(pcall(t.emulate, t, {geolocation={latitude=1}}), false)
@@ -0,0 +1,24 @@
+t = tab.new()
+assert.eq(t:go(TEST.url("/error")).status, 500)
+t:close()
+
+t = tab.new({failOnHTTPError=true})
+assert.eq(t:go(TEST.url()).status, 200)
+
+ok, err = pcall(t.go, t, TEST.url("/error"))
+assert.eq(ok, false)
+assert.eq(err, "testdata/scenario/fail-on-http-error.lua:8: http error: 500 Internal Server Error: " .. TEST.url("/error"))
+
+ok, err = pcall(t.go, t, TEST.url("/redirect/error"))
+assert.eq(ok, false)
+assert.eq(err, "testdata/scenario/fail-on-http-error.lua:12: http error: 500 Internal Server Error: " .. TEST.url("/error") .. " (redirected from " .. TEST.url("/redirect/error") .. " (301))")
+
+ok, err = pcall(t.reload, t)
+assert.eq(ok, false)
+assert.eq(err, "testdata/scenario/fail-on-http-error.lua:16: http error: 500 Internal Server Error: " .. TEST.url("/error"))
+
+ok = pcall(tab.new, {url=TEST.url("/error"), failOnHTTPError=true})
+assert.eq(ok, false)
+
+ok, err = pcall(tab.new, {failOnHTTPError="yes"})
+assert.eq(ok, false)
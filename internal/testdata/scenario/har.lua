@@ -0,0 +1,39 @@
+t = tab.new({url=TEST.url("/?target=first"), har={body=true}})
+t:go(TEST.url("/?target=second"))
+
+t:saveHAR("traffic")
+assert.eq(artifact.list, {"traffic.har"})
+
+har = fromjson(artifact.open("traffic.har"):read("*a"))
+assert.eq(har.log.version, "1.2")
+docs = {}
+for _, x in ipairs(har.log.entries) do
+    if x._resourceType == "Document" then
+        table.insert(docs, x)
+    end
+end
+assert.eq(#docs, 2)
+
+e = docs[2]
+assert.eq(e.request.method, "GET")
+assert.eq(e.request.url, TEST.url("/?target=second"))
+assert.eq(e.request.queryString, {{name="target", value="second"}})
+assert.eq(e.response.status, 200)
+assert.eq(e._resourceType, "Document")
+assert.eq(e.response.content.text:find("second", 1, true) ~= nil, true)
+assert.le(0, e.time)
+
+t:close()
+assert.eq(artifact.list, {"traffic.har"})
+
+
+t = tab.new(TEST.url())
+ok, err = pcall(t.saveHAR, t)
+assert.eq(ok, false)
+assert.eq(err, "testdata/scenario/har.lua:31: HAR recording is not enabled. please use tab.new{har=true}.")
+
+assert.eq(pcall(tab.new, {har="yes"}), false)
+
+t = tab.new({url=TEST.url(), har=true})
+t:close()
+assert.eq(artifact.list, {"traffic.har", "network4.har"})
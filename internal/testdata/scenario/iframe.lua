@@ -0,0 +1,44 @@
+t = tab.new(TEST.url("/frames"))
+
+assert.eq(t("h1").text, "top")
+
+inner = t:frame("local")
+assert.eq(tostring(inner), '$:frame("local")')
+assert.eq(inner("h1").text, TEST.url():sub(8))
+assert.eq(#inner:all("h1"), 1)
+assert.eq(inner:xpath("//h1")[1].text, TEST.url():sub(8))
+assert.eq(inner:eval("location.pathname"), "/frames/child")
+
+inner("input"):sendKeys("hello")
+inner("button"):click()
+assert.eq(inner("button").text, "hello")
+inner:waitText("button", "^hello$")
+
+nested = inner:frame("nested")
+assert.eq(nested("b").text, "nested")
+nested:wait("#greeting")
+assert.eq(nested:waitFor([[document.title]]), "nested - test")
+
+remote = t:frame("#remote")
+assert.eq(remote("h1").text:find("^localhost:") ~= nil, true)
+assert.eq(remote:eval("location.hostname"), "localhost")
+remote("input"):sendKeys("world")
+remote("button"):click()
+assert.eq(remote("button").text, "world")
+assert.eq(remote:frame("nested")("b").text, "nested")
+
+frames = t.frames
+assert.eq(#frames, 2)
+assert.eq(frames[1].name, "local")
+assert.eq(frames[1].url, TEST.url("/frames/child"))
+assert.eq(frames[1].children[1].name, "nested")
+
+ok, err = pcall(t.frame, t, "h1")
+assert.eq(ok, false)
+assert.eq(err:find("h1 is not a frame", 1, true) ~= nil, true)
+
+-- the elements in the frames are highlighted in the recording.
+t = tab.new({url=TEST.url("/frames"), recording=true})
+t:frame("local")("button"):click()
+t:frame("#remote")("button"):click()
+t:close()
@@ -0,0 +1,37 @@
+t = tab.new()
+
+resp = t:go(TEST.url("/?target=response"))
+assert.eq(resp.status, 200)
+assert.eq(resp.url, TEST.url("/?target=response"))
+assert.eq(resp.headers["Content-Type"], "text/html; charset=utf-8")
+assert.eq(t.title, "response - test")
+
+resp = t:go(TEST.url("/redirect"))
+assert.eq(resp.status, 200)
+assert.eq(resp.url, TEST.url("/?target=redirect"))
+assert.eq(t.url, TEST.url("/?target=redirect"))
+
+assert.eq(t:go(TEST.url("/error")).status, 500)
+
+resp = t:go(TEST.url("/?target=fragment#hash"))
+assert.eq(resp.status, 200)
+assert.eq(t:go(TEST.url("/?target=fragment#another")).status, nil)
+assert.eq(t:go(TEST.url("/?target=chain")):wait("#greeting"), t)
+
+t:go(TEST.url("/referer"), {referer=TEST.url("/from")})
+assert.eq(t("body").text, TEST.url("/from"))
+
+t:go(TEST.url("/actionability"), {waitUntil="commit"})
+t:go(TEST.url("/actionability"), {waitUntil="domcontentloaded"})
+assert.eq(t("#result").text, "")
+
+t:go(TEST.url("/actionability"), {waitUntil="networkidle"})
+assert.eq(t.url, TEST.url("/actionability"))
+
+ok, err = pcall(t.go, t, TEST.url(), {waitUntil="never"})
+assert.eq(ok, false)
+assert.eq(err:find('unknown waitUntil: "never"', 1, true) ~= nil, true)
+
+ok, err = pcall(t.go, t, TEST.url("/slow"), {timeout=10})
+assert.eq(ok, false)
+assert.eq(err:find("timeout", 1, true) ~= nil, true)
@@ -0,0 +1,22 @@
+t = tab.new(TEST.url())
+
+m = t.metrics
+assert.le(0, m.ttfb)
+assert.le(m.ttfb, m.domContentLoaded)
+assert.le(m.domContentLoaded, m.load)
+assert.eq(m.cls, 0)
+assert.eq(m.inp, nil)
+assert.le(1, m.cdp.Documents)
+assert.lt(0, m.cdp.JSHeapUsedSize)
+
+m = t:performance("home")
+assert.le(m.domContentLoaded, m.load)
+
+assert.eq(pcall(t.performance, t, "status"), false)
+t:close()
+
+
+t = tab.new({url=TEST.url(), metrics="closed"})
+t:close()
+
+assert.eq(pcall(tab.new, {metrics="latency"}), false)
@@ -25,7 +25,37 @@ end
 time.sleep(100*time.millisecond)
 
 -- generate test data
---print(io.popen("cp " .. artifact.path .. "/record1.gif testdata/gif/record-actual.gif"):read("*a"))
+--print(io.popen("cp " .. artifact.path .. "/record1.gif testdata/gif/record.gif"):read("*a"))
 
-want = io.open("testdata/gif/record.gif", "rb"):read("*a")
-assert(artifact.open("record1.gif", "rb"):read("*a") == want, "recorded gif is different")
+assert(TEST.sameGif("record1.gif", "testdata/gif/record.gif"))
+
+gif = TEST.gif("record1.gif")
+assert.eq(gif.frames, 7)
+for i, d in ipairs(gif.delays) do
+    if i < #gif.delays then
+        assert.le(2, d)
+    else
+        assert.eq(d, 40)
+    end
+end
+
+t = tab.new({
+    url=TEST.url("/"),
+    recording={maxFrames=2},
+    width=300,
+    height=300
+})
+t:go(TEST.url("/dynamic"))
+t("button"):click():click()
+t:close()
+
+while artifact.list[#artifact.list] ~= "record2.gif" do
+    time.sleep(100*time.millisecond)
+end
+while #artifact.open("record2.gif", "rb"):read("*a") == 0 do
+    time.sleep(100*time.millisecond)
+end
+time.sleep(100*time.millisecond)
+
+assert.eq(artifact.list, {"record1.gif", "record2.gif"})
+assert.eq(TEST.gif("record2.gif").frames, 2)
@@ -0,0 +1,58 @@
+t = tab.new()
+
+
+called = false
+t:route(TEST.url("/mocked"), function(req)
+    called = true
+    assert.ne(req.id, "")
+
+    assert.eq(req, {
+        id      = req.id,
+        type    = "Document",
+        url     = TEST.url("/mocked"),
+        method  = "GET",
+        headers = req.headers,
+    })
+
+    return {
+        status  = 200,
+        headers = {["Content-Type"]="text/html"},
+        body    = "<span>this is mocked</span>",
+    }
+end)
+t:go(TEST.url("/mocked"))
+assert.eq(called, true)
+assert.eq(t("span").text, "this is mocked")
+
+
+t:route(TEST.url("/header"), function(req)
+    return {
+        headers = {["X-Header-Test"]="rewritten"},
+    }
+end)
+t:go(TEST.url("/header"))
+assert.eq(t("body").text, [[GET "rewritten"]])
+
+
+t:route("*/error", function(req)
+    return {status=200, body="not an error"}
+end)
+t:go(TEST.url("/error"))
+assert.eq(t("body").text, "not an error")
+
+
+t:route("*/slow", function(req)
+    return false
+end)
+ok, err = pcall(t.go, t, TEST.url("/slow"))
+assert.eq(ok, false)
+assert.eq(err, "testdata/scenario/route.lua:47: page load error net::ERR_BLOCKED_BY_CLIENT")
+
+
+t:route(TEST.url("/mocked"), nil)
+t:route(TEST.url("/header"), nil)
+t:route("*/error", nil)
+t:route("*/slow", nil)
+
+t:go(TEST.url("/header"))
+assert.eq(t("body").text, [[GET ""]])
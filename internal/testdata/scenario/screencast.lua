@@ -0,0 +1,37 @@
+t = tab.new({
+    url=TEST.url("/"),
+    recording="apng",
+    width=300,
+    height=300,
+})
+t:go(TEST.url("/dynamic"))
+t("button"):click()
+t:close()
+
+t = tab.new({
+    url=TEST.url("/"),
+    recording={format="html", source=true},
+    width=300,
+    height=300,
+})
+t:go(TEST.url("/dynamic"))
+t("button"):click()
+t:close()
+
+function find(name)
+    for _, x in ipairs(artifact.list) do
+        if x == name then
+            return true
+        end
+    end
+    return false
+end
+
+while not find("record1.png") or not find("record2.html") do
+    time.sleep(100*time.millisecond)
+end
+
+assert.eq(artifact.open("record1.png", "rb"):read(8), "\x89PNG\r\n\x1a\n")
+assert.eq(find("record2/000001.png"), true)
+
+assert.eq(pcall(tab.new, {recording="mp4"}), false)
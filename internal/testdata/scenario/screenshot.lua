@@ -16,3 +16,21 @@ for _, name in ipairs(artifact.list) do
         artifact.open(name, "rb"):read("*a")
     )
 end
+
+local path = t:screenshot("full", {fullPage=true})
+assert.eq(artifact.list[#artifact.list], "full.png")
+assert.eq(path:sub(-#"full.png"), "full.png")
+
+t:screenshot({format="jpeg", quality=50})
+assert.eq(artifact.list[#artifact.list], "000003.jpg")
+
+t:screenshot("clipped", {clip={x=0, y=0, width=10, height=20}, format="webp"})
+assert.eq(artifact.list[#artifact.list], "clipped.webp")
+
+path = t("b"):screenshot("transparent", {omitBackground=true, scale=2})
+assert.eq(artifact.list[#artifact.list], "transparent.png")
+assert.eq(io.open(path, "rb"):read("*a"), artifact.open("transparent.png", "rb"):read("*a"))
+
+assert.eq(pcall(t.screenshot, t, {format="gif"}), false)
+assert.eq(pcall(t.screenshot, t, {fullPage=true, clip={x=0, y=0, width=1, height=1}}), false)
+assert.eq(pcall(t("b").screenshot, t("b"), {fullPage=true}), false)
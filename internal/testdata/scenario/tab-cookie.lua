@@ -0,0 +1,168 @@
+t = tab.new(TEST.url("/cookie/get"))
+assert.eq(t("body").text, "not set")
+assert.eq(t.cookies, {})
+
+
+t:go(TEST.url("/cookie/set"))
+assert.eq(t.cookies, {{
+    name     = "cookie_test",
+    value    = "hello world",
+    path     = "/cookie",
+    domain   = "127.0.0.1",
+    secure   = false,
+    httponly = false,
+    samesite = "",
+}})
+
+t:go(TEST.url("/cookie/get"))
+assert.eq(t("body").text, "hello world")
+
+
+t:go(TEST.url("/cookie/strict"))
+assert.eq(t.cookies, {{
+    name     = "strict_test",
+    value    = "hello strict",
+    path     = "/",
+    domain   = "127.0.0.1",
+    secure   = false,
+    httponly = true,
+    samesite = "strict",
+}})
+
+
+t:clearCookies()
+assert.eq(t.cookies, {})
+t:go(TEST.url("/cookie/get"))
+assert.eq(t("body").text, "not set")
+
+
+t:setCookie({name="cookie_test", value="injected"})
+assert.eq(t.cookies, {{
+    name     = "cookie_test",
+    value    = "injected",
+    path     = "/cookie",
+    domain   = "127.0.0.1",
+    secure   = false,
+    httponly = false,
+    samesite = "",
+}})
+t:go(TEST.url("/cookie/get"))
+assert.eq(t("body").text, "injected")
+
+
+t:clearCookies()
+expires = time.now() + time.hour
+t:setCookie({
+    name     = "cookie_test",
+    value    = "with options",
+    url      = TEST.url("/"),
+    path     = "/",
+    expires  = expires,
+    httponly = true,
+    samesite = "lax",
+})
+t:go(TEST.url("/cookie/get"))
+assert.eq(t("body").text, "with options")
+
+cookie = t.cookies[1]
+assert.eq(t.cookies, {{
+    name     = "cookie_test",
+    value    = "with options",
+    path     = "/",
+    domain   = "127.0.0.1",
+    expires  = cookie.expires,
+    secure   = false,
+    httponly = true,
+    samesite = "lax",
+}})
+assert.lt(math.abs(cookie.expires - expires), time.second)
+
+
+-- share cookies from fetch to tab
+t:clearCookies()
+
+resp, jar = fetch(TEST.url("/cookie/set"))
+assert.eq(resp:read("*all"), "ok")
+
+t:useCookies(jar)
+t:go(TEST.url("/cookie/get"))
+assert.eq(t("body").text, "hello world")
+
+t:clearCookies()
+t2 = tab.new({url=TEST.url("/cookie/get"), cookiejar=jar})
+assert.eq(t2("body").text, "hello world")
+t2:close()
+
+-- an empty jar is also acceptable
+t:clearCookies()
+_, empty = fetch(TEST.url("/"))
+t:useCookies(empty)
+t:go(TEST.url("/cookie/get"))
+assert.eq(t("body").text, "not set")
+
+t2 = tab.new({url=TEST.url("/cookie/get"), cookiejar=empty})
+assert.eq(t2("body").text, "not set")
+t2:close()
+
+
+-- share cookies from tab to fetch
+t:clearCookies()
+t:go(TEST.url("/cookie/strict"))
+
+jar = t:cookiejar()
+assert.eq(tostring(jar):sub(1, 10), "cookiejar#")
+assert.eq(jar:get(TEST.url("/")), {{
+    name     = "strict_test",
+    value    = "hello strict",
+    path     = "",
+    domain   = "",
+    secure   = false,
+    httponly = false,
+    samesite = "",
+}})
+
+t:clearCookies()
+t:go(TEST.url("/cookie/set"))
+resp = fetch(TEST.url("/cookie/get"), {cookiejar=t:cookiejar()})
+assert.eq(resp:read("*all"), "hello world")
+
+t:clearCookies()
+
+
+-- save and restore state
+-- Use localhost for Web Storage, because other tests use 127.0.0.1 at the same time.
+local storageURL = TEST.url("/"):gsub("127%.0%.0%.1", "localhost")
+
+t:go(TEST.url("/cookie/set"))
+t:go(storageURL)
+t.localStorage:set("state_test", "saved")
+t:saveState("login")
+
+f = io.open(TEST.storage("../state/login.json"))
+assert.ne(f, nil)
+f:close()
+
+t:clearCookies()
+t.localStorage:clear()
+t:close()
+
+t = tab.new({url=TEST.url("/cookie/get"), state="login"})
+assert.eq(t("body").text, "hello world")
+t:go(storageURL)
+assert.eq(t.localStorage:get("state_test"), "saved")
+
+t.localStorage:set("state_test", "changed")
+t:reload()
+assert.eq(t.localStorage:get("state_test"), "changed")
+
+assert.eq(t:loadState("login"), true)
+assert.eq(t.localStorage:get("state_test"), "saved")
+
+assert.eq(t:loadState("unknown"), false)
+
+t:saveState("expired", 0)
+assert.eq(t:loadState("expired"), false)
+
+t.localStorage:clear()
+t:clearCookies()
+t:close()
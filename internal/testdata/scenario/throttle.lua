@@ -0,0 +1,32 @@
+t = tab.new(TEST.url())
+
+t:throttle("offline")
+ok, err = pcall(t.go, t, TEST.url("/?target=offline"))
+assert.eq(ok, false)
+assert.eq(err, "testdata/scenario/throttle.lua:4: page load error net::ERR_INTERNET_DISCONNECTED")
+
+t:throttle(nil)
+t:go(TEST.url("/?target=online"))
+assert.eq(t("b").text, "online")
+
+
+t:throttle({latency=500})
+local start = time.now()
+t:go(TEST.url("/?target=latency"))
+assert.eq(t("b").text, "latency")
+assert.le(500, time.now() - start)
+
+
+-- throttling keeps working even if hooks removed.
+t:throttle("offline")
+t:onRequest(function() end)
+t:onRequest(nil)
+ok, err = pcall(t.go, t, TEST.url("/?target=offline"))
+assert.eq(ok, false)
+
+t:throttle(false)
+t:go(TEST.url("/?target=fine"))
+assert.eq(t("b").text, "fine")
+
+
+assert.eq(pcall(t.throttle, t, "5G"), false)
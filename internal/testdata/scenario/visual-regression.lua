@@ -0,0 +1,29 @@
+t = tab.new(TEST.url())
+
+-- The first time creates the baseline.
+assert.screenshot(t, "top")
+assert.screenshot(t, "top")
+assert.screenshot(t("#greeting"), "greeting")
+assert.eq(artifact.list, {})
+
+t:eval([[ document.querySelector(".target").innerText = "changed" ]])
+
+ok, err = pcall(assert.screenshot, t, "top")
+assert.eq(ok, false)
+assert.eq(err:match("assertion failed: screenshot is [0-9.]+%% different from baseline \"top\" %(threshold: 0.00%%%)") ~= nil, true)
+assert.eq(artifact.list, {"top.actual.png", "top.diff.png"})
+
+assert.screenshot(t, "top", {threshold=0.5})
+assert.screenshot(t, "top", {mask={"#greeting"}})
+assert.screenshot(t("#greeting"), "greeting", {mask="#greeting"})
+
+assert.eq(pcall(assert.screenshot, t, "../escape"), false)
+assert.eq(pcall(assert.screenshot, "tab", "top"), false)
+
+-- The masks are in the same frame as the element.
+t:go(TEST.url("/frames"))
+inner = t:frame("local")
+assert.screenshot(inner("h1"), "frame-title")
+inner:eval([[ document.querySelector("h1").innerText = "changed" ]])
+assert.eq(pcall(assert.screenshot, inner("h1"), "frame-title"), false)
+assert.screenshot(inner("h1"), "frame-title", {mask="h1"})
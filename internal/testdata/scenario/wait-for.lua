@@ -0,0 +1,41 @@
+t = tab.new({url=TEST.url("/actionability"), timeout=5*time.second})
+
+assert.eq(t:waitFor([[document.querySelector("#appear")?.textContent]]), "hello")
+
+count = 0
+assert.eq(t:waitFor(function(tab)
+    count = count + 1
+    return count >= 3 and tab.url
+end, {interval=10}), TEST.url("/actionability"))
+assert.eq(count, 3)
+
+ok, err = pcall(t.waitFor, t, "false", {timeout=100})
+assert.eq(ok, false)
+assert.eq(err:find("timeout", 1, true) ~= nil, true)
+
+ok = pcall(t.waitFor, t, function() return false end, {timeout=100})
+assert.eq(ok, false)
+
+t:go(TEST.url("/actionability"))
+t:waitText("#result", "^appear")
+assert.eq(t("#result").text, "appeared")
+
+t:eval([[document.querySelector("#late").remove()]])
+t:waitGone("#late")
+t:waitHidden("#late")
+t:eval([[setTimeout(() => document.querySelector("#hidden").style.visibility = "hidden", 100)]])
+t:waitHidden("#hidden")
+
+t:eval([[setTimeout(() => history.pushState(null, "", "/actionability/next"), 100)]])
+t:waitURL("/next$")
+
+t:eval([[setTimeout(() => fetch("/slow"), 100)]])
+t:waitNetworkIdle()
+
+-- requests started before the call are waited after the first call.
+t:eval([[window.fetched = false; fetch("/slow").then(() => { window.fetched = true })]])
+t:waitNetworkIdle()
+assert.eq(t:eval("window.fetched"), true)
+
+ok = pcall(t.waitGone, t, "body", 100)
+assert.eq(ok, false)
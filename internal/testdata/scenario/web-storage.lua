@@ -0,0 +1,53 @@
+t = tab.new(TEST.url("/"))
+
+
+assert.eq(tostring(t.localStorage), "localStorage")
+assert.eq(tostring(t.sessionStorage), "sessionStorage")
+
+assert.eq(t.localStorage:keys(), {})
+assert.eq(t.localStorage:get("hello"), nil)
+
+t.localStorage:set("hello", "world"):set("number", 123):set("table", tojson({foo="bar"}))
+assert.eq(t.localStorage:keys(), {"hello", "number", "table"})
+assert.eq(t.localStorage:get("hello"), "world")
+assert.eq(t.localStorage:get("number"), "123")
+assert.eq(fromjson(t.localStorage:get("table")), {foo="bar"})
+assert.eq(t:eval([[ localStorage.getItem("hello") ]]), "world")
+assert.eq(t.localStorage:all(), {hello="world", number="123", table=[[{"foo":"bar"}]]})
+
+ok, err = pcall(t.localStorage.set, t.localStorage, "table", {foo="bar"})
+assert.eq(ok, false)
+assert.eq(err:find("string expected", 1, true) ~= nil, true)
+
+t.localStorage:remove("number")
+assert.eq(t.localStorage:keys(), {"hello", "table"})
+
+t:eval([[ localStorage.setItem("fromjs", "ok") ]])
+assert.eq(t.localStorage:get("fromjs"), "ok")
+
+t.localStorage:clear()
+assert.eq(t.localStorage:keys(), {})
+
+
+t.sessionStorage:set("session", "value")
+assert.eq(t.sessionStorage:all(), {session="value"})
+assert.eq(t.localStorage:all(), {})
+assert.eq(t:eval([[ sessionStorage.getItem("session") ]]), "value")
+
+
+t:go(TEST.url("/indexeddb"))
+t:wait("#done")
+assert.eq(t:indexedDB(), {
+    testdb = {
+        users = {
+            {key=1, value={id=1, name="alice", tags={"admin"}}},
+            {key=2, value={id=2, name="bob", tags={}}},
+        },
+    },
+})
+
+
+t:go("about:blank")
+ok, err = pcall(t.localStorage.keys, t.localStorage)
+assert.eq(ok, false)
+assert.eq(err, "testdata/scenario/web-storage.lua:47: about:blank has no origin")
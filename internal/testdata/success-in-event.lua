@@ -0,0 +1,11 @@
+t = tab.new(TEST.url("/"))
+
+t:route("*", function(req)
+end)
+
+t:go(TEST.url("/"))
+t:reload()
+
+t:close()
+
+finished = true
@@ -0,0 +1,72 @@
+package webscenario
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// NetworkConditions is a setting of network throttling.
+// Latency is in milliseconds, and Download and Upload are in bytes per second. Negative throughput means no limit.
+type NetworkConditions struct {
+	Offline  bool
+	Latency  float64
+	Download float64
+	Upload   float64
+}
+
+// NetworkPresets is a set of network conditions that based on Chrome DevTools.
+var NetworkPresets = map[string]NetworkConditions{
+	"offline": {Offline: true, Download: -1, Upload: -1},
+	"slow 3g": {Latency: 2000, Download: 50000, Upload: 50000},
+	"fast 3g": {Latency: 562.5, Download: 180000, Upload: 84375},
+	"slow 4g": {Latency: 562.5, Download: 180000, Upload: 84375},
+	"fast 4g": {Latency: 165, Download: 1012500, Upload: 168750},
+}
+
+// UnpackNetworkConditions reads network conditions from a preset name or a table.
+// It returns nil if the value is nil or false, that means no throttling.
+func UnpackNetworkConditions(L *lua.LState, lv lua.LValue) (*NetworkConditions, error) {
+	switch v := lv.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		if !v {
+			return nil, nil
+		}
+	case lua.LString:
+		if c, ok := NetworkPresets[strings.ToLower(string(v))]; ok {
+			return &c, nil
+		}
+		return nil, fmt.Errorf("unknown network preset: %q", string(v))
+	case *lua.LTable:
+		c := &NetworkConditions{
+			Offline:  lua.LVAsBool(L.GetField(v, "offline")),
+			Download: -1,
+			Upload:   -1,
+		}
+		for name, dst := range map[string]*float64{"latency": &c.Latency, "download": &c.Download, "upload": &c.Upload} {
+			switch n := L.GetField(v, name).(type) {
+			case *lua.LNilType:
+			case lua.LNumber:
+				*dst = float64(n)
+			default:
+				return nil, fmt.Errorf("%s field expected number.", name)
+			}
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("a preset name or a table expected.")
+}
+
+// Action makes an action to apply the conditions.
+// If c is nil, the action disables throttling.
+func (c *NetworkConditions) Action() chromedp.Action {
+	if c == nil {
+		return network.EmulateNetworkConditions(false, 0, -1, -1)
+	}
+	return network.EmulateNetworkConditions(c.Offline, c.Latency, c.Download, c.Upload)
+}
@@ -0,0 +1,51 @@
+package webscenario
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/yuin/gopher-lua"
+)
+
+func TestUnpackNetworkConditions(t *testing.T) {
+	tests := []struct {
+		Script string
+		Want   *NetworkConditions
+		Error  string
+	}{
+		{`return nil`, nil, ""},
+		{`return false`, nil, ""},
+		{`return "Slow 3G"`, &NetworkConditions{Latency: 2000, Download: 50000, Upload: 50000}, ""},
+		{`return "offline"`, &NetworkConditions{Offline: true, Download: -1, Upload: -1}, ""},
+		{`return {latency=100}`, &NetworkConditions{Latency: 100, Download: -1, Upload: -1}, ""},
+		{`return {download=1000, upload=500, offline=false}`, &NetworkConditions{Download: 1000, Upload: 500}, ""},
+		{`return "5G"`, nil, `unknown network preset: "5G"`},
+		{`return {latency="fast"}`, nil, "latency field expected number."},
+		{`return 123`, nil, "a preset name or a table expected."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Script, func(t *testing.T) {
+			L := lua.NewState()
+			defer L.Close()
+
+			if err := L.DoString(tt.Script); err != nil {
+				t.Fatalf("failed to prepare value: %s", err)
+			}
+
+			c, err := UnpackNetworkConditions(L, L.Get(-1))
+			if tt.Error != "" {
+				if err == nil || err.Error() != tt.Error {
+					t.Fatalf("expected error %q but got %v", tt.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tt.Want, c); diff != "" {
+				t.Errorf("unexpected conditions:\n%s", diff)
+			}
+		})
+	}
+}
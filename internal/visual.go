@@ -0,0 +1,354 @@
+package webscenario
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// pixelTolerance is the maximum difference of each color channel that is treated as the same color.
+// It absorbs tiny differences of anti-aliasing.
+const pixelTolerance = 8
+
+// viewportRectScript returns the visible area of the page in document coordinates.
+const viewportRectScript = `(() => {
+	const t = document.documentElement.getBoundingClientRect();
+	return {x: -t.left, y: -t.top, width: document.documentElement.clientWidth, height: document.documentElement.clientHeight};
+})()`
+
+// nodeRectFunction returns the area of the node in document coordinates.
+const nodeRectFunction = `function() {
+	const e = this.getBoundingClientRect(), t = this.ownerDocument.documentElement.getBoundingClientRect();
+	return {x: e.left - t.left, y: e.top - t.top, width: e.width, height: e.height};
+}`
+
+// comparisonRectsFunction returns the area of this node, or the viewport if this is a document, and the areas of the elements that match to the selectors in the same document.
+// The areas are in the viewport coordinates of the top document in the same process, so the positions of the iframes in the same process are added.
+const comparisonRectsFunction = `function(selectors) {
+	const doc = this.ownerDocument || this;
+	let x = 0, y = 0;
+	for (let w = doc.defaultView; w.frameElement; w = w.parent) {
+		const f = w.frameElement, r = f.getBoundingClientRect(), s = w.parent.getComputedStyle(f);
+		x += r.left + f.clientLeft + parseFloat(s.paddingLeft);
+		y += r.top + f.clientTop + parseFloat(s.paddingTop);
+	}
+	const rect = (e) => {
+		const r = e.getBoundingClientRect();
+		return {x: r.left + x, y: r.top + y, width: r.width, height: r.height};
+	};
+	return {
+		area: this === doc ? {x: x, y: y, width: doc.documentElement.clientWidth, height: doc.documentElement.clientHeight} : rect(this),
+		masks: selectors.flatMap((s) => [...doc.querySelectorAll(s)].map(rect)),
+	};
+}`
+
+type documentRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// ToImage converts the rect into the coordinates in the screenshot of clip.
+func (r documentRect) ToImage(clip documentRect, scale float64) image.Rectangle {
+	return image.Rect(
+		int(math.Floor((r.X-clip.X)*scale)),
+		int(math.Floor((r.Y-clip.Y)*scale)),
+		int(math.Ceil((r.X-clip.X+r.Width)*scale)),
+		int(math.Ceil((r.Y-clip.Y+r.Height)*scale)),
+	)
+}
+
+func nodeRect(ctx context.Context, nodeID cdp.NodeID) (documentRect, error) {
+	var r documentRect
+
+	obj, err := dom.ResolveNode().WithNodeID(nodeID).Do(ctx)
+	if err != nil {
+		return r, err
+	}
+	defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+
+	err = chromedp.CallFunctionOn(nodeRectFunction, &r, func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+		return p.WithObjectID(obj.ObjectID)
+	}).Do(ctx)
+	return r, err
+}
+
+// captureForComparison takes a screenshot of the clip and finds the areas to mask in the screenshot.
+// The clip is the viewport if node is nil, otherwise the area of the node.
+// The masks are the elements in the same document as the node.
+//
+// The areas are moved into the document coordinates of the tab, and the screenshot is taken from the tab, because the frames can not take a screenshot by themselves.
+func captureForComparison(ctx context.Context, frame *Frame, nodeID *cdp.NodeID, maskSelectors []string) (image.Image, []image.Rectangle, error) {
+	if nodeID == nil {
+		doc, err := dom.GetDocument().Do(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		nodeID = &doc.NodeID
+	}
+
+	obj, err := dom.ResolveNode().WithNodeID(*nodeID).Do(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+
+	if maskSelectors == nil {
+		maskSelectors = []string{}
+	}
+	var rects struct {
+		Area  documentRect   `json:"area"`
+		Masks []documentRect `json:"masks"`
+	}
+	err = chromedp.CallFunctionOn(comparisonRectsFunction, &rects, func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+		return p.WithObjectID(obj.ObjectID)
+	}, maskSelectors).Do(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offset, err := frame.offset()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var img image.Image
+	var clip documentRect
+	err = chromedp.Run(frame.tab.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var viewport documentRect
+		if err := chromedp.Evaluate(viewportRectScript, &viewport).Do(ctx); err != nil {
+			return err
+		}
+
+		toDocument := func(r documentRect) documentRect {
+			return documentRect{r.X + float64(offset.X) + viewport.X, r.Y + float64(offset.Y) + viewport.Y, r.Width, r.Height}
+		}
+		clip = toDocument(rects.Area)
+		for i, r := range rects.Masks {
+			rects.Masks[i] = toDocument(r)
+		}
+
+		// Align to integer like chromedp.Screenshot does.
+		x, y := math.Round(clip.X), math.Round(clip.Y)
+		clip = documentRect{x, y, math.Round(clip.Width + clip.X - x), math.Round(clip.Height + clip.Y - y)}
+		if clip.Width <= 0 || clip.Height <= 0 {
+			return errors.New("the element has no size")
+		}
+
+		raw, err := page.CaptureScreenshot().
+			WithFormat(page.CaptureScreenshotFormatPng).
+			WithCaptureBeyondViewport(true).
+			WithClip(&page.Viewport{X: clip.X, Y: clip.Y, Width: clip.Width, Height: clip.Height, Scale: 1}).
+			Do(ctx)
+		if err != nil {
+			return err
+		}
+		img, err = png.Decode(bytes.NewReader(raw))
+		return err
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var masks []image.Rectangle
+	scale := float64(img.Bounds().Dx()) / clip.Width
+	for _, r := range rects.Masks {
+		masks = append(masks, r.ToImage(clip, scale).Add(img.Bounds().Min))
+	}
+
+	return img, masks, nil
+}
+
+func colorDiff(a, b uint32) uint32 {
+	if a > b {
+		return (a - b) >> 8
+	}
+	return (b - a) >> 8
+}
+
+// CompareImages compares two images and makes a diff image that highlights changed pixels in red.
+// The pixels in masks are ignored, and painted in gray in the diff image.
+// It returns the ratio of changed pixels, or 1 if the sizes are different.
+func CompareImages(baseline, actual image.Image, masks []image.Rectangle) (diff *image.RGBA, ratio float64) {
+	bRect, aRect := baseline.Bounds(), actual.Bounds()
+
+	diff = image.NewRGBA(image.Rect(0, 0, aRect.Dx(), aRect.Dy()))
+
+	changed, total := 0, 0
+	for y := 0; y < aRect.Dy(); y++ {
+		for x := 0; x < aRect.Dx(); x++ {
+			ar, ag, ab, _ := actual.At(aRect.Min.X+x, aRect.Min.Y+y).RGBA()
+			// Unchanged pixels are drawn in pale gray to make changes stand out.
+			gray := uint8(((ar+ag+ab)/3)>>8)/4 + 192
+
+			masked := false
+			for _, m := range masks {
+				if (image.Point{aRect.Min.X + x, aRect.Min.Y + y}).In(m) {
+					masked = true
+					break
+				}
+			}
+			if masked {
+				diff.Set(x, y, color.RGBA{128, 128, 128, 255})
+				continue
+			}
+
+			total++
+			if x >= bRect.Dx() || y >= bRect.Dy() {
+				changed++
+				diff.Set(x, y, color.RGBA{255, 0, 0, 255})
+				continue
+			}
+
+			br, bg, bb, _ := baseline.At(bRect.Min.X+x, bRect.Min.Y+y).RGBA()
+			if colorDiff(ar, br) > pixelTolerance || colorDiff(ag, bg) > pixelTolerance || colorDiff(ab, bb) > pixelTolerance {
+				changed++
+				diff.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				diff.Set(x, y, color.RGBA{gray, gray, gray, 255})
+			}
+		}
+	}
+
+	if bRect.Dx() != aRect.Dx() || bRect.Dy() != aRect.Dy() {
+		return diff, 1
+	}
+	if total == 0 {
+		return diff, 0
+	}
+	return diff, float64(changed) / float64(total)
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+// RegisterAssertScreenshot adds assert.screenshot() into the assert table.
+func RegisterAssertScreenshot(L *lua.LState) {
+	L.SetField(L.GetGlobal("assert"), "screenshot", L.NewFunction(AssertScreenshot))
+}
+
+// AssertScreenshot compares a screenshot of a tab or an element with the baseline image.
+// If the baseline doesn't exist yet, it saves the screenshot as the baseline and passes with a log.
+func AssertScreenshot(L *lua.LState) int {
+	var frame *Frame
+	var nodeID *cdp.NodeID
+	var name string
+
+	switch v := L.CheckUserData(1).Value.(type) {
+	case *Tab:
+		frame = v.main
+		name = "$"
+	case Element:
+		frame = v.frame
+		nodeID = &v.node.NodeID
+		name = v.name
+	default:
+		L.ArgError(1, "tab or element expected.")
+	}
+
+	baseline := L.CheckString(2)
+	if !validFileName(baseline) {
+		L.ArgError(2, "invalid baseline name.")
+	}
+
+	threshold := 0.0
+	var masks []string
+	if opts, ok := L.Get(3).(*lua.LTable); ok {
+		switch th := L.GetField(opts, "threshold").(type) {
+		case *lua.LNilType:
+		case lua.LNumber:
+			threshold = float64(th)
+		default:
+			L.ArgError(3, "threshold field expected number value.")
+		}
+		switch m := L.GetField(opts, "mask").(type) {
+		case *lua.LNilType:
+		case lua.LString:
+			masks = append(masks, string(m))
+		case *lua.LTable:
+			ipairs(m, func(_, s lua.LValue) {
+				if ss, ok := s.(lua.LString); ok {
+					masks = append(masks, string(ss))
+				} else {
+					L.ArgError(3, "mask field expected list of string.")
+				}
+			})
+		default:
+			L.ArgError(3, "mask field expected string or table value.")
+		}
+	} else if L.Get(3) != lua.LNil {
+		L.ArgError(3, "table expected.")
+	}
+
+	t := frame.tab
+	path := filepath.Join(t.env.storage.BaselineDir, baseline+".png")
+
+	var ratio float64
+	var created bool
+	frame.Run(L, fmt.Sprintf("assert.screenshot(%s, %q)", name, baseline), false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		actual, maskRects, err := captureForComparison(ctx, frame, nodeID, masks)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			created = true
+			raw, err := encodePNG(actual)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+				return err
+			}
+			return os.WriteFile(path, raw, 0644)
+		} else if err != nil {
+			return err
+		}
+		base, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read baseline: %w", err)
+		}
+
+		var diff *image.RGBA
+		diff, ratio = CompareImages(base, actual, maskRects)
+		if ratio <= threshold {
+			return nil
+		}
+
+		if raw, err := encodePNG(actual); err == nil {
+			t.Save(baseline+".actual", ".png", raw)
+		}
+		if raw, err := encodePNG(diff); err == nil {
+			t.Save(baseline+".diff", ".png", raw)
+		}
+		return nil
+	}))
+
+	if created {
+		t.env.logger.Print(lua.LString(fmt.Sprintf("assert.screenshot: baseline %q is created", baseline)))
+	} else if ratio > threshold {
+		L.RaiseError("assertion failed: screenshot is %.2f%% different from baseline %q (threshold: %.2f%%)", ratio*100, baseline, threshold*100)
+	}
+
+	return 0
+}
@@ -0,0 +1,65 @@
+package webscenario
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func fillImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCompareImages(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	black := color.RGBA{0, 0, 0, 255}
+
+	base := fillImage(10, 10, white)
+
+	if _, r := CompareImages(base, fillImage(10, 10, white), nil); r != 0 {
+		t.Errorf("same images should be 0 but got %f", r)
+	}
+
+	if _, r := CompareImages(base, fillImage(10, 10, color.RGBA{250, 252, 255, 255}), nil); r != 0 {
+		t.Errorf("tiny difference should be ignored but got %f", r)
+	}
+
+	actual := fillImage(10, 10, white)
+	for x := 0; x < 10; x++ {
+		actual.Set(x, 0, black)
+	}
+	diff, r := CompareImages(base, actual, nil)
+	if r != 0.1 {
+		t.Errorf("expected 0.1 but got %f", r)
+	}
+	if c := diff.RGBAAt(0, 0); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("changed pixel should be red but got %v", c)
+	}
+	if c := diff.RGBAAt(0, 1); c.R != c.G || c.R < 192 {
+		t.Errorf("unchanged pixel should be pale gray but got %v", c)
+	}
+
+	if _, r := CompareImages(base, actual, []image.Rectangle{image.Rect(0, 0, 10, 1)}); r != 0 {
+		t.Errorf("masked pixels should be ignored but got %f", r)
+	}
+
+	if _, r := CompareImages(base, fillImage(10, 12, white), nil); r != 1 {
+		t.Errorf("different size should be 1 but got %f", r)
+	}
+}
+
+func Test_documentRect_ToImage(t *testing.T) {
+	clip := documentRect{X: 100, Y: 50, Width: 200, Height: 100}
+	r := documentRect{X: 110.5, Y: 60, Width: 20, Height: 10.2}
+
+	want := image.Rect(21, 20, 61, 41)
+	if got := r.ToImage(clip, 2); got != want {
+		t.Errorf("expected %v but got %v", want, got)
+	}
+}
@@ -0,0 +1,360 @@
+package webscenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/pm"
+)
+
+const (
+	// DefaultPollingInterval is the interval to check conditions of waitFor and other waits.
+	DefaultPollingInterval = 100 * time.Millisecond
+
+	// NetworkIdleTime is the duration without network requests to treat as network idle.
+	NetworkIdleTime = 500 * time.Millisecond
+)
+
+// NetworkTracker keeps in-flight requests of a tab to detect network idle.
+// It only knows requests while the network events are enabled.
+type NetworkTracker struct {
+	sync.Mutex
+
+	inflight map[network.RequestID]struct{}
+	last     time.Time
+}
+
+func NewNetworkTracker() *NetworkTracker {
+	return &NetworkTracker{
+		inflight: make(map[network.RequestID]struct{}),
+		last:     time.Now(),
+	}
+}
+
+// Start records a request started. Long-lived requests like EventSource are ignored.
+func (n *NetworkTracker) Start(id network.RequestID, typ network.ResourceType) {
+	if typ == network.ResourceTypeEventSource || typ == network.ResourceTypeWebSocket {
+		return
+	}
+	n.Lock()
+	defer n.Unlock()
+	n.inflight[id] = struct{}{}
+	n.last = time.Now()
+}
+
+// Finish records a request finished or failed.
+func (n *NetworkTracker) Finish(id network.RequestID) {
+	n.Lock()
+	defer n.Unlock()
+	if _, ok := n.inflight[id]; ok {
+		delete(n.inflight, id)
+		n.last = time.Now()
+	}
+}
+
+// Reset forgets all in-flight requests.
+// It should be called when the network events are enabled, because requests that started before can't be tracked.
+func (n *NetworkTracker) Reset() {
+	n.Lock()
+	defer n.Unlock()
+	n.inflight = make(map[network.RequestID]struct{})
+	n.last = time.Now()
+}
+
+// IdleFor returns how long the network has been idle, or 0 if there are in-flight requests.
+func (n *NetworkTracker) IdleFor(now time.Time) time.Duration {
+	n.Lock()
+	defer n.Unlock()
+	if len(n.inflight) > 0 {
+		return 0
+	}
+	return now.Sub(n.last)
+}
+
+// WaitIdle waits until no request has been in flight for the idle duration.
+func (n *NetworkTracker) WaitIdle(ctx context.Context, idle time.Duration) error {
+	for {
+		d := n.IdleFor(time.Now())
+		if d >= idle {
+			return nil
+		}
+		wait := idle - d
+		if d == 0 || wait > DefaultPollingInterval {
+			wait = DefaultPollingInterval
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// pollScript makes an action to evaluate the JavaScript expression in the frame until it returns truthy value.
+// A Promise is awaited. Exceptions in the expression stop polling, but other errors like navigation are ignored.
+func pollScript(f *Frame, expr string, interval time.Duration, res *any) chromedp.Action {
+	script := fmt.Sprintf("(async () => { const v = await (%s); return v ? [v] : null; })()", expr)
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for {
+			var v []any
+			err := f.Evaluate(script, &v, true).Do(ctx)
+
+			var exc *runtime.ExceptionDetails
+			switch {
+			case errors.As(err, &exc):
+				return err
+			case err == nil && len(v) > 0:
+				if res != nil {
+					*res = v[0]
+				}
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+	})
+}
+
+// pollValue makes an action to get a value by get until match returns true.
+func pollValue[T any](get func(context.Context) (T, error), match func(T) (bool, error), interval time.Duration) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for {
+			v, err := get(ctx)
+			if err == nil {
+				if ok, err := match(v); err != nil || ok {
+					return err
+				}
+			} else if ctx.Err() != nil {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+	})
+}
+
+// matchPattern checks if the string matches to the Lua pattern.
+func matchPattern(pattern, s string) (bool, error) {
+	m, err := pm.Find(pattern, []byte(s), 0, 1)
+	if err != nil {
+		return false, err
+	}
+	return len(m) > 0, nil
+}
+
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (f *Frame) WaitFor(L *lua.LState) int {
+	timeout := f.tab.timeout
+	interval := DefaultPollingInterval
+	if opts := L.OptTable(3, nil); opts != nil {
+		switch v := L.GetField(opts, "timeout").(type) {
+		case *lua.LNilType:
+		case lua.LNumber:
+			timeout = 0
+			if v > 0 {
+				timeout = time.Duration(float64(v) * float64(time.Millisecond))
+			}
+		default:
+			L.ArgError(3, "timeout field expected number value.")
+		}
+		switch v := L.GetField(opts, "interval").(type) {
+		case *lua.LNilType:
+		case lua.LNumber:
+			if v <= 0 {
+				L.ArgError(3, "interval field should be greater than 0.")
+			}
+			interval = time.Duration(float64(v) * float64(time.Millisecond))
+		default:
+			L.ArgError(3, "interval field expected number value.")
+		}
+	}
+
+	switch pred := L.Get(2).(type) {
+	case lua.LString:
+		var res any
+		f.Run(L, fmt.Sprintf("%s:waitFor([[ %s ]])", f.name, pred), true, timeout, pollScript(f, string(pred), interval, &res))
+		L.Push(PackLValue(L, res))
+		return 1
+	case *lua.LFunction:
+		taskName := f.name + ":waitFor(function)"
+		f.tab.env.StartTask(L.Where(1), taskName)
+
+		var deadline time.Time
+		if timeout > 0 {
+			deadline = time.Now().Add(timeout)
+		}
+		for {
+			L.Push(pred)
+			L.Push(L.Get(1))
+			L.Call(1, 1)
+			v := L.Get(-1)
+			L.Pop(1)
+			if lua.LVAsBool(v) {
+				f.tab.RecordOnce(L, taskName)
+				L.Push(v)
+				return 1
+			}
+
+			wait := interval
+			if !deadline.IsZero() {
+				remain := time.Until(deadline)
+				if remain <= 0 {
+					L.RaiseError("timeout")
+				}
+				if remain < wait {
+					wait = remain
+				}
+			}
+			AsyncRun(f.tab.env, L, func() (struct{}, error) {
+				select {
+				case <-f.ctx.Done():
+					return struct{}{}, f.ctx.Err()
+				case <-time.After(wait):
+					return struct{}{}, nil
+				}
+			})
+		}
+	default:
+		L.ArgError(2, "function or string expected.")
+		return 0
+	}
+}
+
+func (f *Frame) WaitText(L *lua.LState) {
+	query := L.CheckString(2)
+	pattern := L.CheckString(3)
+	timeout := f.tab.OptTimeout(L, 4)
+
+	if _, err := matchPattern(pattern, ""); err != nil {
+		L.ArgError(3, err.Error())
+	}
+
+	script := fmt.Sprintf("(() => { const e = document.querySelector(%s); return e ? e.innerText : null; })()", jsString(query))
+	f.Run(
+		L,
+		fmt.Sprintf("%s:waitText(%q, %q)", f.name, query, pattern),
+		true,
+		timeout,
+		pollValue(
+			func(ctx context.Context) (text *string, err error) {
+				err = f.Evaluate(script, &text, false).Do(ctx)
+				return text, err
+			},
+			func(text *string) (bool, error) {
+				if text == nil {
+					return false, nil
+				}
+				return matchPattern(pattern, *text)
+			},
+			DefaultPollingInterval,
+		),
+	)
+}
+
+func (f *Frame) WaitHidden(L *lua.LState) {
+	query := L.CheckString(2)
+	timeout := f.tab.OptTimeout(L, 3)
+
+	script := fmt.Sprintf(`(() => {
+		const e = document.querySelector(%s);
+		if (!e) return true;
+		const r = e.getBoundingClientRect();
+		return r.width === 0 || r.height === 0 || getComputedStyle(e).visibility !== "visible";
+	})()`, jsString(query))
+	f.Run(L, fmt.Sprintf("%s:waitHidden(%q)", f.name, query), true, timeout, pollScript(f, script, DefaultPollingInterval, nil))
+}
+
+func (f *Frame) WaitGone(L *lua.LState) {
+	query := L.CheckString(2)
+	timeout := f.tab.OptTimeout(L, 3)
+
+	script := fmt.Sprintf("document.querySelector(%s) === null", jsString(query))
+	f.Run(L, fmt.Sprintf("%s:waitGone(%q)", f.name, query), true, timeout, pollScript(f, script, DefaultPollingInterval, nil))
+}
+
+func (t *Tab) WaitURL(L *lua.LState) {
+	pattern := L.CheckString(2)
+	timeout := t.OptTimeout(L, 3)
+
+	if _, err := matchPattern(pattern, ""); err != nil {
+		L.ArgError(2, err.Error())
+	}
+
+	t.Run(
+		L,
+		fmt.Sprintf("$:waitURL(%q)", pattern),
+		true,
+		timeout,
+		pollValue(
+			func(ctx context.Context) (url string, err error) {
+				err = chromedp.Location(&url).Do(ctx)
+				return url, err
+			},
+			func(url string) (bool, error) {
+				return matchPattern(pattern, url)
+			},
+			DefaultPollingInterval,
+		),
+	)
+}
+
+// withNetwork makes an action that runs the action with the network events enabled.
+// The network events are disabled again after the action if the tab doesn't need them.
+func (t *Tab) withNetwork(action chromedp.Action) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if t.networkEnabled() {
+			return action.Do(ctx)
+		}
+
+		t.network.Reset()
+		if err := network.Enable().Do(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			if !t.networkEnabled() {
+				t.RunInCallback(network.Disable())
+				t.network.Reset()
+			}
+		}()
+		return action.Do(ctx)
+	})
+}
+
+func (t *Tab) WaitNetworkIdle(L *lua.LState) {
+	timeout := t.OptTimeout(L, 2)
+
+	// The requests started before enabling the network events can't be tracked, so keep it enabled for the next call.
+	first := !t.networkEnabled()
+	t.networkIdle = true
+
+	t.Run(L, "$:waitNetworkIdle()", true, timeout, chromedp.ActionFunc(func(ctx context.Context) error {
+		if first {
+			t.network.Reset()
+			if err := network.Enable().Do(ctx); err != nil {
+				return err
+			}
+		}
+		return t.network.WaitIdle(ctx, NetworkIdleTime)
+	}))
+}
@@ -0,0 +1,80 @@
+package webscenario
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestNetworkTracker(t *testing.T) {
+	t.Parallel()
+
+	n := NewNetworkTracker()
+	now := time.Now()
+
+	n.Start("1", network.ResourceTypeDocument)
+	n.Start("2", network.ResourceTypeEventSource)
+	if d := n.IdleFor(now.Add(time.Hour)); d != 0 {
+		t.Errorf("expected not idle but idle for %s", d)
+	}
+
+	n.Finish("1")
+	if d := n.IdleFor(time.Now().Add(time.Second)); d < time.Second-10*time.Millisecond {
+		t.Errorf("expected idle for 1s but got %s", d)
+	}
+
+	n.Start("3", network.ResourceTypeFetch)
+	n.Reset()
+	if d := n.IdleFor(time.Now().Add(time.Second)); d < time.Second-10*time.Millisecond {
+		t.Errorf("expected idle after reset but got %s", d)
+	}
+
+	n.Start("4", network.ResourceTypeFetch)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		n.Finish("4")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stime := time.Now()
+	if err := n.WaitIdle(ctx, 100*time.Millisecond); err != nil {
+		t.Fatalf("failed to wait idle: %s", err)
+	}
+	if d := time.Since(stime); d < 150*time.Millisecond {
+		t.Errorf("returned too early: %s", d)
+	}
+
+	n.Start("5", network.ResourceTypeFetch)
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := n.WaitIdle(ctx, 10*time.Millisecond); err == nil {
+		t.Errorf("expected timeout but succeeded")
+	}
+}
+
+func Test_matchPattern(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Input   string
+		Match   bool
+	}{
+		{"hello", "hello world", true},
+		{"^world", "hello world", false},
+		{"/users/%d+$", "https://example.com/users/42", true},
+		{"/users/%d+$", "https://example.com/users/me", false},
+	}
+	for _, tt := range tests {
+		ok, err := matchPattern(tt.Pattern, tt.Input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.Pattern, err)
+		} else if ok != tt.Match {
+			t.Errorf("%q %q: expected %v but got %v", tt.Pattern, tt.Input, tt.Match, ok)
+		}
+	}
+
+	if _, err := matchPattern("[a", ""); err == nil {
+		t.Errorf("expected error for malformed pattern")
+	}
+}
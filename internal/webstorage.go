@@ -0,0 +1,275 @@
+package webscenario
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/chromedp/cdproto/domstorage"
+	"github.com/chromedp/cdproto/indexeddb"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/yuin/gopher-lua"
+)
+
+// pageOrigin returns the origin of the current page, like "https://example.com".
+func pageOrigin(ctx context.Context) (string, error) {
+	var loc string
+	if err := chromedp.Location(&loc).Do(ctx); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(loc)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%s has no origin", loc)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+type WebStorage struct {
+	name    string
+	tab     *Tab
+	isLocal bool
+}
+
+func NewWebStorage(t *Tab, isLocal bool) WebStorage {
+	name := "$.sessionStorage"
+	if isLocal {
+		name = "$.localStorage"
+	}
+	return WebStorage{
+		name:    name,
+		tab:     t,
+		isLocal: isLocal,
+	}
+}
+
+func (s WebStorage) ToLua(L *lua.LState) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = s
+	L.SetMetatable(ud, L.GetTypeMetatable("webstorage"))
+	return ud
+}
+
+func CheckWebStorage(L *lua.LState) WebStorage {
+	if ud, ok := L.Get(1).(*lua.LUserData); ok {
+		if s, ok := ud.Value.(WebStorage); ok {
+			return s
+		}
+	}
+
+	L.ArgError(1, "storage expected. perhaps you call it like tab.localStorage.xxx() instead of tab.localStorage:xxx().")
+	return WebStorage{}
+}
+
+// do executes f with StorageID for the current page.
+func (s WebStorage) do(L *lua.LState, taskName string, f func(ctx context.Context, id *domstorage.StorageID) error) {
+	s.tab.Run(L, taskName, false, 0, chromedp.ActionFunc(func(ctx context.Context) error {
+		origin, err := pageOrigin(ctx)
+		if err != nil {
+			return err
+		}
+		return f(ctx, &domstorage.StorageID{
+			SecurityOrigin: origin,
+			IsLocalStorage: s.isLocal,
+		})
+	}))
+}
+
+func (s WebStorage) items(L *lua.LState, taskName string) map[string]string {
+	var items []domstorage.Item
+	s.do(L, taskName, func(ctx context.Context, id *domstorage.StorageID) (err error) {
+		items, err = domstorage.GetDOMStorageItems(id).Do(ctx)
+		return err
+	})
+
+	m := make(map[string]string)
+	for _, item := range items {
+		if len(item) == 2 {
+			m[item[0]] = item[1]
+		}
+	}
+	return m
+}
+
+func (s WebStorage) Get(L *lua.LState) int {
+	key := L.CheckString(2)
+
+	if v, ok := s.items(L, fmt.Sprintf("%s:get(%q)", s.name, key))[key]; ok {
+		L.Push(lua.LString(v))
+	} else {
+		L.Push(lua.LNil)
+	}
+	return 1
+}
+
+// Set sets a value. Web Storage can store only strings, so the value has to be a string or a number as same as get returns.
+func (s WebStorage) Set(L *lua.LState) {
+	key := L.CheckString(2)
+
+	var value string
+	switch v := L.CheckAny(3).(type) {
+	case lua.LString:
+		value = string(v)
+	case lua.LNumber:
+		value = v.String()
+	default:
+		L.ArgError(3, "string expected. please use tojson() to store other values.")
+	}
+
+	s.do(L, fmt.Sprintf("%s:set(%q)", s.name, key), func(ctx context.Context, id *domstorage.StorageID) error {
+		return domstorage.SetDOMStorageItem(id, key, value).Do(ctx)
+	})
+}
+
+func (s WebStorage) Remove(L *lua.LState) {
+	key := L.CheckString(2)
+
+	s.do(L, fmt.Sprintf("%s:remove(%q)", s.name, key), func(ctx context.Context, id *domstorage.StorageID) error {
+		return domstorage.RemoveDOMStorageItem(id, key).Do(ctx)
+	})
+}
+
+func (s WebStorage) Clear(L *lua.LState) {
+	s.do(L, fmt.Sprintf("%s:clear()", s.name), func(ctx context.Context, id *domstorage.StorageID) error {
+		return domstorage.Clear(id).Do(ctx)
+	})
+}
+
+func (s WebStorage) Keys(L *lua.LState) int {
+	items := s.items(L, fmt.Sprintf("%s:keys()", s.name))
+
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	L.Push(PackLValue(L, keys))
+	return 1
+}
+
+func (s WebStorage) All(L *lua.LState) int {
+	L.Push(PackLValue(L, s.items(L, fmt.Sprintf("%s:all()", s.name))))
+	return 1
+}
+
+func RegisterWebStorageType(L *lua.LState) {
+	fn := func(f func(WebStorage, *lua.LState)) lua.LGFunction {
+		return func(L *lua.LState) int {
+			f(CheckWebStorage(L), L)
+			L.Push(L.Get(1))
+			return 1
+		}
+	}
+
+	fret := func(f func(WebStorage, *lua.LState) int) lua.LGFunction {
+		return func(L *lua.LState) int {
+			return f(CheckWebStorage(L), L)
+		}
+	}
+
+	meta := L.NewTypeMetatable("webstorage")
+	L.SetField(meta, "__index", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"get":    fret(WebStorage.Get),
+		"set":    fn(WebStorage.Set),
+		"remove": fn(WebStorage.Remove),
+		"clear":  fn(WebStorage.Clear),
+		"keys":   fret(WebStorage.Keys),
+		"all":    fret(WebStorage.All),
+	}))
+	L.SetField(meta, "__tostring", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(CheckWebStorage(L).name[2:]))
+		return 1
+	}))
+}
+
+// remoteObjectValue converts a RemoteObject into a Go value.
+func remoteObjectValue(ctx context.Context, obj *runtime.RemoteObject) (any, error) {
+	raw := []byte(obj.Value)
+
+	if obj.ObjectID != "" {
+		defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+
+		res, exp, err := runtime.CallFunctionOn("function() { return this }").
+			WithObjectID(obj.ObjectID).
+			WithReturnByValue(true).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if exp != nil {
+			return nil, exp
+		}
+		raw = []byte(res.Value)
+	}
+
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var v any
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+// dumpIndexedDB reads all data in IndexedDB of the origin.
+func dumpIndexedDB(ctx context.Context, origin string) (map[string]map[string][]map[string]any, error) {
+	if err := indexeddb.Enable().Do(ctx); err != nil {
+		return nil, err
+	}
+
+	names, err := indexeddb.RequestDatabaseNames().WithSecurityOrigin(origin).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string][]map[string]any)
+	for _, name := range names {
+		db, err := indexeddb.RequestDatabase(name).WithSecurityOrigin(origin).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		stores := make(map[string][]map[string]any)
+		for _, store := range db.ObjectStores {
+			entries := []map[string]any{}
+
+			for hasMore := true; hasMore; {
+				var es []*indexeddb.DataEntry
+				es, hasMore, err = indexeddb.RequestData(name, store.Name, "", int64(len(entries)), 100).WithSecurityOrigin(origin).Do(ctx)
+				if err != nil {
+					return nil, err
+				}
+				if len(es) == 0 {
+					break
+				}
+
+				for _, e := range es {
+					key, err := remoteObjectValue(ctx, e.PrimaryKey)
+					if err != nil {
+						return nil, err
+					}
+					value, err := remoteObjectValue(ctx, e.Value)
+					if err != nil {
+						return nil, err
+					}
+					entries = append(entries, map[string]any{
+						"key":   key,
+						"value": value,
+					})
+				}
+			}
+
+			stores[store.Name] = entries
+		}
+		result[name] = stores
+	}
+
+	return result, nil
+}
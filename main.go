@@ -62,6 +62,18 @@ func main() {
 	flags.BoolVar(&arg.Debug, "debug", false, "enable debug mode.")
 	flags.BoolVar(&arg.Head, "head", false, "show browser window while execution.")
 	flags.BoolVar(&arg.Recording, "gif", false, "enable recording animation gif.")
+	flags.StringVar(&arg.Screencast, "screencast", "", "enable recording screencast in \"apng\" or \"html\" format.")
+	flags.DurationVar(&arg.DefaultTimeout, "default-timeout", webscenario.DefaultTimeout, "default timeout for waiting elements and actions. 0 or negative value means no limit.")
+	flags.BoolVar(&arg.FailOnHTTPError, "fail-on-http-error", false, "make navigations fail when the page responds HTTP error status.")
+	flags.StringVar(&arg.UserDataDir, "user-data-dir", "", "path to browser profile directory to keep between executions.")
+	flags.StringVar(&arg.BrowserURL, "browser-url", "", "URL of DevTools to connect running browser instead of launching new one. (e.g. ws://127.0.0.1:9222)")
+	flags.StringVar(&arg.Browser.ExecPath, "browser-path", "", "path to the browser executable.")
+	flags.StringVar(&arg.Browser.Proxy, "proxy", "", "proxy server for the browser. (e.g. http://proxy.example.com:8080)")
+	flags.StringVar(&arg.Browser.Lang, "lang", "", "language of the browser. (e.g. en-US)")
+	flags.BoolVar(&arg.Browser.IgnoreCertErrors, "ignore-certificate-errors", false, "ignore TLS certificate errors in the browser.")
+	flags.BoolVar(&arg.Evidence, "evidence", false, "save screenshot, HTML, and logs of each tab when the scenario failed.")
+	windowSize := flags.String("window-size", "", "window size of the browser. (e.g. 1280x720)")
+	flags.StringArrayVar(&arg.Browser.Flags, "browser-flag", nil, "extra command line flag for the browser. (e.g. --browser-flag=--disable-gpu)")
 	showVersion := flags.BoolP("version", "v", false, "show version and exit.")
 	showHelp := flags.BoolP("help", "h", false, "show help message and exit.")
 
@@ -95,6 +107,20 @@ func main() {
 		return
 	}
 
+	if arg.Screencast != "" && arg.Screencast != webscenario.ScreencastAPNG && arg.Screencast != webscenario.ScreencastHTML {
+		fmt.Fprintf(os.Stderr, "unsupported screencast format: %s\nPlease see `%s -h` for more information.\n", arg.Screencast, os.Args[0])
+		os.Exit(2)
+	}
+
+	if *windowSize != "" {
+		var err error
+		arg.Browser.WindowWidth, arg.Browser.WindowHeight, err = webscenario.ParseWindowSize(*windowSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\nPlease see `%s -h` for more information.\n", err, os.Args[0])
+			os.Exit(2)
+		}
+	}
+
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {